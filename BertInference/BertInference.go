@@ -6,10 +6,10 @@ package BertInference
 import (
 	"fmt"
 	"log"
-	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -19,32 +19,57 @@ import (
 )
 
 type BERTSentiment struct {
-	Label      string  `json:"label"`
-	Confidence float64 `json:"confidence"`
-	Score      float64 `json:"score"`
+	Label         string      `json:"label"`
+	Confidence    float64     `json:"confidence"`
+	Score         float64     `json:"score"`
+	Probabilities ClassScores `json:"probabilities"`
+	Logits        ClassScores `json:"logits"`
+	// Model is the name@version that produced the result
+	Model string `json:"model"`
+	// Engine is "onnx" for the model or "lexicon" for the fallback
+	Engine string `json:"engine"`
 }
-type BERTModel struct {
-	session        *ort.DynamicAdvancedSession
-	vocab          map[string]int
-	isInitialized  bool
-	mutex          sync.RWMutex
-	inputNames     []string
-	outputNames    []string
-	inferenceMutex sync.Mutex
+
+// registry holds the loaded models by name. Reloading swaps the whole set
+// at once; models that are replaced are closed once their last request is
+// done.
+type registry struct {
+	mutex sync.RWMutex
+	// reloadMutex serialises reloads, so each one loads against the models
+	// actually in place and never reuses one another reload has closed
+	reloadMutex  sync.Mutex
+	models       map[string]*model
+	defaultName  string
+	shadowNames  []string
+	manifestPath string
+	chunking     chunkConfig
 }
 
+// inferenceTimeout bounds how long a caller waits for queued inference
+const inferenceTimeout = 30 * time.Second
+
 var (
-	globalModel    = &BERTModel{}
+	globalRegistry = &registry{}
 	envInitialized = false
 	envMutex       = sync.Mutex{}
 )
 
-func InitializeBERT(modelPath, vocabPath string) error {
-	globalModel.mutex.Lock()
-	defer globalModel.mutex.Unlock()
+// IsInitialized reports whether at least one model is loaded and ready for
+// inference
+func IsInitialized() bool {
+	globalRegistry.mutex.RLock()
+	defer globalRegistry.mutex.RUnlock()
+	return len(globalRegistry.models) > 0
+}
+
+// InitializeBERT starts the ONNX Runtime environment and loads every model
+// in the manifest
+func InitializeBERT(manifestPath string) error {
+	globalRegistry.mutex.Lock()
+	defer globalRegistry.mutex.Unlock()
 
-	if globalModel.isInitialized {
-		log.Println("BERT model already initialized")
+	if len(globalRegistry.models) > 0 {
+		log.Println("BERT models already initialized")
 		return nil
 	}
 	envMutex.Lock()
@@ -73,28 +98,148 @@ func InitializeBERT(modelPath, vocabPath string) error {
 	}
 	envMutex.Unlock()
 
-	vocab, err := LoadVocab(vocabPath)
-	globalModel.vocab = vocab
+	manifest, err := LoadManifest(manifestPath)
 	if err != nil {
-		return fmt.Errorf("failed to load vocab: %v", err)
+		return fmt.Errorf("failed to load model manifest: %v", err)
 	}
+	models, err := loadModels(manifest, nil)
+	if err != nil {
+		return err
+	}
+
+	globalRegistry.models = models
+	globalRegistry.defaultName = manifest.Default
+	globalRegistry.shadowNames = manifest.Shadow
+	globalRegistry.manifestPath = manifestPath
+	globalRegistry.chunking = chunkConfigFromEnv()
+	log.Printf("BERT chunking: overlap %d tokens, max %d chunks, headline weight %.2f",
+		globalRegistry.chunking.overlap, globalRegistry.chunking.maxChunks, globalRegistry.chunking.headlineWeight)
 
-	globalModel.inputNames = []string{"input_ids", "attention_mask", "token_type_ids"}
-	globalModel.outputNames = []string{"logits"}
+	log.Printf("BERT models initialized successfully (default %s)", manifest.Default)
+	return nil
+}
 
-	globalModel.session, err = ort.NewDynamicAdvancedSession(
-		modelPath,
-		globalModel.inputNames,
-		globalModel.outputNames,
-		nil,
-	)
+// loadModels loads every model in the manifest, reusing a current model
+// when its spec is unchanged. If any model fails, the ones loaded so far are
+// closed and nothing is returned.
+func loadModels(manifest *Manifest, current map[string]*model) (map[string]*model, error) {
+	models := make(map[string]*model, len(manifest.Models))
+	for _, spec := range manifest.Models {
+		if m, ok := current[spec.Name]; ok && sameSpec(m.spec, spec) {
+			models[spec.Name] = m
+			continue
+		}
+		m, err := loadModel(spec)
+		if err != nil {
+			for name, loaded := range models {
+				if current[name] != loaded {
+					loaded.close()
+				}
+			}
+			return nil, err
+		}
+		models[spec.Name] = m
+	}
+	return models, nil
+}
+
+func sameSpec(a, b ModelSpec) bool {
+	return a.Name == b.Name && a.Version == b.Version && a.Path == b.Path &&
+		a.Vocab == b.Vocab && a.Config == b.Config && a.Calibration == b.Calibration &&
+		a.MaxLength == b.MaxLength &&
+		strings.Join(a.Labels, ",") == strings.Join(b.Labels, ",")
+}
+
+// ReloadModels re-reads the manifest and swaps in the new set of models
+// without dropping requests. Models whose entry is unchanged are kept as
+// they are unless force is set; replaced and removed models are unloaded
+// once their in-flight requests finish. On error the current models stay
+// in place.
+func ReloadModels(force bool) ([]ModelInfo, error) {
+	globalRegistry.reloadMutex.Lock()
+	defer globalRegistry.reloadMutex.Unlock()
+
+	globalRegistry.mutex.RLock()
+	manifestPath := globalRegistry.manifestPath
+	current := globalRegistry.models
+	globalRegistry.mutex.RUnlock()
+
+	if manifestPath == "" {
+		return nil, fmt.Errorf("BERT model not initialized - call InitializeBERT first")
+	}
+	manifest, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load model manifest: %v", err)
+	}
+	if force {
+		current = nil
+	}
+	models, err := loadModels(manifest, current)
 	if err != nil {
-		return fmt.Errorf("failed to create ONNX session: %v", err)
+		return nil, err
 	}
 
-	globalModel.isInitialized = true
-	log.Println("BERT model initialized successfully")
-	return nil
+	globalRegistry.mutex.Lock()
+	old := globalRegistry.models
+	globalRegistry.models = models
+	globalRegistry.defaultName = manifest.Default
+	globalRegistry.shadowNames = manifest.Shadow
+	globalRegistry.mutex.Unlock()
+
+	for name, m := range old {
+		if models[name] != m {
+			go m.close()
+		}
+	}
+	log.Printf("BERT models reloaded from %s (default %s)", manifestPath, manifest.Default)
+	return Models(), nil
+}
+
+// Models describes the loaded models, sorted by name
+func Models() []ModelInfo {
+	globalRegistry.mutex.RLock()
+	defer globalRegistry.mutex.RUnlock()
+
+	infos := make([]ModelInfo, 0, len(globalRegistry.models))
+	for name, m := range globalRegistry.models {
+		info := m.info()
+		info.Default = name == globalRegistry.defaultName
+		info.Shadow = slices.Contains(globalRegistry.shadowNames, name)
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// ShadowModels describes the loaded models listed as shadows of the default
+func ShadowModels() []ModelInfo {
+	var shadows []ModelInfo
+	for _, info := range Models() {
+		if info.Shadow {
+			shadows = append(shadows, info)
+		}
+	}
+	return shadows
+}
+
+// acquireModel returns the named model, or the default for "", and marks it
+// in use so a reload cannot unload it mid-request. Callers must release it.
+func acquireModel(name string) (*model, chunkConfig, error) {
+	globalRegistry.mutex.RLock()
+	defer globalRegistry.mutex.RUnlock()
+
+	if len(globalRegistry.models) == 0 {
+		return nil, chunkConfig{}, fmt.Errorf("BERT model not initialized - call InitializeBERT first")
+	}
+	if name == "" {
+		name = globalRegistry.defaultName
+	}
+	m, ok := globalRegistry.models[name]
+	if !ok {
+		return nil, chunkConfig{}, fmt.Errorf("%w: %q", ErrUnknownModel, name)
+	}
+	m.users.Add(1)
+	return m, globalRegistry.chunking, nil
 }
 
 // setPlatformSpecificLibraryPath sets the correct ONNX Runtime library path based on the OS
@@ -194,12 +339,19 @@ func fileExists(path string) bool {
 }
 
 func CleanupBERT() {
-	globalModel.mutex.Lock()
-	defer globalModel.mutex.Unlock()
+	// Let a reload under way finish so its models are closed too
+	globalRegistry.reloadMutex.Lock()
+	defer globalRegistry.reloadMutex.Unlock()
 
-	if globalModel.session != nil {
-		globalModel.session.Destroy()
-		globalModel.session = nil
+	globalRegistry.mutex.Lock()
+	models := globalRegistry.models
+	globalRegistry.models = nil
+	globalRegistry.mutex.Unlock()
+
+	// Each close waits for in-flight requests so no session is destroyed
+	// while running
+	for _, m := range models {
+		m.close()
 	}
 
 	envMutex.Lock()
@@ -209,218 +361,43 @@ func CleanupBERT() {
 	}
 	envMutex.Unlock()
 
-	globalModel.isInitialized = false
 	log.Println("BERT model cleaned up")
 }
 
-func RunBERTInference(text string, modelPath string) (*BERTSentiment, error) {
-	timeout := time.After(30 * time.Second)
-	resultChan := make(chan *BERTSentiment, 1)
-	errorChan := make(chan error, 1)
-
-	go func() {
-		result, err := runBERTInferenceInternal(text, modelPath)
-		if err != nil {
-			errorChan <- err
-		} else {
-			resultChan <- result
-		}
-	}()
-	select {
-	case result := <-resultChan:
-		return result, nil
-	case err := <-errorChan:
-		return nil, err
-	case <-timeout:
-		return nil, fmt.Errorf("BERT inference timeout after 30 seconds")
-	}
-}
-
-func runBERTInferenceInternal(text string, modelPath string) (*BERTSentiment, error) {
-	globalModel.mutex.RLock()
-	initialized := globalModel.isInitialized
-	globalModel.mutex.RUnlock()
-
-	if !initialized {
-		globalModel.mutex.Lock()
-		if !globalModel.isInitialized {
-			return nil, fmt.Errorf("BERT model not initialized - call InitializeBERT first")
-		}
-		globalModel.mutex.Unlock()
-	}
-
-	// Serialize inference to prevent concurrent access issues
-	globalModel.inferenceMutex.Lock()
-	defer globalModel.inferenceMutex.Unlock()
-
-	globalModel.mutex.RLock()
-	if !globalModel.isInitialized {
-		globalModel.mutex.RUnlock()
-		return nil, fmt.Errorf("model not initialized")
-	}
-
-	// Encode input
-	inputIds, attentionMask, tokenTypeIds := BertEncode(text, globalModel.vocab, 256)
-
-	inputIdsInt := make([]int64, len(inputIds))
-	attentionMaskInt := make([]int64, len(attentionMask))
-	tokenTypeIdsInt := make([]int64, len(tokenTypeIds))
-	globalModel.mutex.RUnlock()
-
-	for i := range inputIds {
-		inputIdsInt[i] = int64(inputIds[i])
-		attentionMaskInt[i] = int64(attentionMask[i])
-		tokenTypeIdsInt[i] = int64(tokenTypeIds[i])
-	}
-
-	inputShape := ort.NewShape(1, 256)
-
-	inputTensor, err := ort.NewTensor(inputShape, inputIdsInt)
+// RunBERTInference scores one text with the default model
+func RunBERTInference(text string) (*BERTSentiment, error) {
+	results, err := RunBERTInferenceBatch([]string{text})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create input tensor: %v", err)
-	}
-	defer func() {
-		if inputTensor != nil {
-			inputTensor.Destroy()
-		}
-	}()
-
-	attentionTensor, err := ort.NewTensor(inputShape, attentionMaskInt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create attention tensor: %v", err)
-	}
-	defer func() {
-		if attentionTensor != nil {
-			attentionTensor.Destroy()
-		}
-	}()
-	tokenTypeTensor, err := ort.NewTensor(inputShape, tokenTypeIdsInt)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create token type tensor: %v", err)
-	}
-	defer func() {
-		if tokenTypeTensor != nil {
-			tokenTypeTensor.Destroy()
-		}
-	}()
-
-	outputShape := ort.NewShape(1, 3)
-	outputTensor, err := ort.NewEmptyTensor[float32](outputShape)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create output tensor: %v", err)
-	}
-	defer func() {
-		if outputTensor != nil {
-			outputTensor.Destroy()
-		}
-	}()
-
-	//
-	// Prepare inputs and outputs slices for the Run method
-	//
-	inputs := []ort.Value{inputTensor, attentionTensor, tokenTypeTensor}
-	outputs := []ort.Value{outputTensor}
-
-	globalModel.mutex.RLock()
-	if globalModel.session == nil {
-		globalModel.mutex.RUnlock()
-		return nil, fmt.Errorf("session is nil")
-	}
-
-	err = globalModel.session.Run(inputs, outputs)
-	globalModel.mutex.RUnlock()
-	if err != nil {
-		return nil, fmt.Errorf("failed to run inference: %v", err)
-	}
-
-	logits := outputTensor.GetData()
-	if len(logits) < 3 {
-		return nil, fmt.Errorf("insufficient logits: got %d, expected at least 3", len(logits))
+		return nil, err
 	}
-
-	fmt.Printf("BERT Logits: %v\n", logits[:3])
-	return ProcessLogits(logits[:3]), nil
+	return results[0], nil
 }
 
-func initializeBERTUnsafe(modelPath, vocabPath string) error {
-	// Set the path based on your platform
-	_, err := setPlatformSpecificLibraryPath()
-	if err != nil {
-		return fmt.Errorf("failed to set library path: %v", err)
+// RunBERTInferenceBatch scores several texts at once with the default
+// model. The texts are queued with any concurrent requests and run in as
+// few model calls as possible. Each text is truncated to one model window;
+// use RunBERTInferenceDocuments for long text.
+func RunBERTInferenceBatch(texts []string) ([]*BERTSentiment, error) {
+	if len(texts) == 0 {
+		return nil, nil
 	}
 
-	log.Println("Attempting to initialize ONNX Runtime environment...")
-	err2 := ort.InitializeEnvironment()
-	if err2 != nil {
-		return fmt.Errorf("failed to initialize ONNX Runtime: %v", err2)
-	}
-
-	// Load vocabulary
-	globalModel.vocab, err = LoadVocab(vocabPath)
-	if err != nil {
-		return fmt.Errorf("failed to load vocab: %v", err)
-	}
-
-	globalModel.inputNames = []string{"input_ids", "attention_mask", "token_type_ids"}
-	globalModel.outputNames = []string{"logits"}
-
-	// Using DynamicAdvancedSession
-	globalModel.session, err = ort.NewDynamicAdvancedSession(
-		modelPath,
-		globalModel.inputNames,
-		globalModel.outputNames,
-		nil, // Use default session options
-	)
+	m, _, err := acquireModel("")
 	if err != nil {
-		return fmt.Errorf("failed to create ONNX session: %v", err)
+		return nil, err
 	}
+	defer m.release()
 
-	globalModel.isInitialized = true
-	log.Println("BERT model initialized successfully")
-	return nil
+	return m.infer(texts)
 }
 
+// ProcessLogits turns one row of logits into a sentiment using the default
+// model's label order and calibration
 func ProcessLogits(logits []float32) *BERTSentiment {
-	if len(logits) < 3 {
-		return &BERTSentiment{
-			Label:      "neutral",
-			Confidence: 0.0,
-			Score:      0.0,
-		}
-	}
-	maxLogit := float32(math.Inf(-1))
-	for _, logit := range logits[:3] {
-		if logit > maxLogit {
-			maxLogit = logit
-		}
-	}
-	var sum float32 = 0
-	probs := make([]float32, 3)
-	// exponential confidence
-	for i := 0; i <= 2; i++ {
-		probs[i] = float32(math.Exp(float64(logits[i] - maxLogit)))
-		sum += probs[i]
-	}
-	for i := range probs {
-		probs[i] /= sum
-	}
-	maxIdx := 0
-	maxProb := probs[0]
-	for i := 1; i < 3; i++ {
-		if probs[i] > maxProb {
-			maxProb = probs[i]
-			maxIdx = i
-		}
-	}
-
-	labels := []string{"negative", "neutral", "positive"}
-
-	// Calculate sentiment score (-1 to 1)
-	sentimentScore := float64(probs[2] - probs[0]) // positive - negative
-
-	return &BERTSentiment{
-		Label:      labels[maxIdx],
-		Confidence: float64(maxProb),
-		Score:      sentimentScore,
+	m, _, err := acquireModel("")
+	if err != nil {
+		return processLogits(logits, DefaultLabels, nil)
 	}
+	defer m.release()
+	return m.sentiment(logits)
 }
@@ -0,0 +1,195 @@
+//go:build onnx
+// +build onnx
+
+package BertInference
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+var errBatcherStopped = errors.New("BERT batcher stopped")
+
+// batchFunc runs the model once over a batch of tokenized sequences on the
+// given session and returns the logits for each
+type batchFunc func(session *ort.DynamicAdvancedSession, seqs [][]string) ([][]float32, error)
+
+type batchRequest struct {
+	tokens   []string
+	enqueued time.Time
+	result   chan batchResult
+}
+
+type batchResult struct {
+	logits []float32
+	err    error
+}
+
+// batcher queues single-sequence requests and coalesces them into one model
+// run of up to maxBatch sequences. A batch is dispatched as soon as it is full, or
+// maxWait after its first request arrived, whichever comes first. Batches
+// run in parallel, one per free session in the pool.
+type batcher struct {
+	model    string
+	requests chan batchRequest
+	maxBatch int
+	maxWait  time.Duration
+	run      batchFunc
+	pool     *sessionPool
+	inflight sync.WaitGroup
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newBatcher(model string, maxBatch int, maxWait time.Duration, pool *sessionPool, run batchFunc) *batcher {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	return &batcher{
+		model:    model,
+		requests: make(chan batchRequest, maxBatch*4),
+		maxBatch: maxBatch,
+		maxWait:  maxWait,
+		run:      run,
+		pool:     pool,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// batcherConfigFromEnv reads BERT_MAX_BATCH_SIZE and BERT_BATCH_TIMEOUT_MS
+func batcherConfigFromEnv() (int, time.Duration) {
+	maxBatch := 16
+	if n, err := strconv.Atoi(os.Getenv("BERT_MAX_BATCH_SIZE")); err == nil && n > 0 {
+		maxBatch = n
+	}
+	maxWait := 10 * time.Millisecond
+	if ms, err := strconv.Atoi(os.Getenv("BERT_BATCH_TIMEOUT_MS")); err == nil && ms >= 0 {
+		maxWait = time.Duration(ms) * time.Millisecond
+	}
+	return maxBatch, maxWait
+}
+
+func (b *batcher) start() {
+	go b.loop()
+}
+
+// close stops accepting requests, fails anything still queued and waits
+// for the loop to exit. In-flight batches are allowed to finish.
+func (b *batcher) close() {
+	close(b.stop)
+	<-b.done
+}
+
+// submitAll queues every sequence and waits for all logits, up to timeout
+func (b *batcher) submitAll(seqs [][]string, timeout time.Duration) ([][]float32, error) {
+	reqs := make([]batchRequest, len(seqs))
+	for i, tokens := range seqs {
+		reqs[i] = batchRequest{tokens: tokens, enqueued: time.Now(), result: make(chan batchResult, 1)}
+		queueDepth.WithLabelValues(b.model).Inc()
+		select {
+		case b.requests <- reqs[i]:
+		case <-b.stop:
+			queueDepth.WithLabelValues(b.model).Dec()
+			return nil, errBatcherStopped
+		}
+	}
+
+	deadline := time.After(timeout)
+	out := make([][]float32, len(seqs))
+	for i := range reqs {
+		select {
+		case res := <-reqs[i].result:
+			if res.err != nil {
+				return nil, res.err
+			}
+			out[i] = res.logits
+		case <-b.done:
+			return nil, errBatcherStopped
+		case <-deadline:
+			return nil, errors.New("BERT inference timeout after " + timeout.String())
+		}
+	}
+	return out, nil
+}
+
+func (b *batcher) loop() {
+	defer close(b.done)
+	defer b.inflight.Wait()
+
+	for {
+		var first batchRequest
+		select {
+		case <-b.stop:
+			b.drain()
+			return
+		case first = <-b.requests:
+			queueDepth.WithLabelValues(b.model).Dec()
+		}
+
+		batch := []batchRequest{first}
+		if b.maxBatch > 1 {
+			timer := time.NewTimer(b.maxWait)
+		collect:
+			for len(batch) < b.maxBatch {
+				select {
+				case req := <-b.requests:
+					queueDepth.WithLabelValues(b.model).Dec()
+					batch = append(batch, req)
+				case <-timer.C:
+					break collect
+				}
+			}
+			timer.Stop()
+		}
+
+		// Wait for a free session; requests keep queuing meanwhile and
+		// form the next batch
+		ps := b.pool.acquire()
+		b.inflight.Add(1)
+		go func() {
+			defer b.inflight.Done()
+			start := time.Now()
+			b.dispatch(ps.session, batch)
+			b.pool.release(ps, time.Since(start))
+		}()
+	}
+}
+
+// dispatch runs one batch and hands each caller its own result
+func (b *batcher) dispatch(session *ort.DynamicAdvancedSession, batch []batchRequest) {
+	now := time.Now()
+	seqs := make([][]string, len(batch))
+	for i, req := range batch {
+		seqs[i] = req.tokens
+		queueWait.WithLabelValues(b.model).Observe(now.Sub(req.enqueued).Seconds())
+	}
+	batchSize.WithLabelValues(b.model).Observe(float64(len(batch)))
+
+	results, err := b.run(session, seqs)
+	batchDuration.WithLabelValues(b.model).Observe(time.Since(now).Seconds())
+	for i, req := range batch {
+		if err != nil {
+			req.result <- batchResult{err: err}
+			continue
+		}
+		req.result <- batchResult{logits: results[i]}
+	}
+}
+
+func (b *batcher) drain() {
+	for {
+		select {
+		case req := <-b.requests:
+			queueDepth.WithLabelValues(b.model).Dec()
+			req.result <- batchResult{err: errBatcherStopped}
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package BertInference
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// Calibration methods
+const (
+	CalibrationTemperature = "temperature"
+	CalibrationIsotonic    = "isotonic"
+)
+
+// Calibration maps a model's raw logits to probabilities that match how
+// often its predictions are right, so confidence thresholds mean what they
+// say. It is fitted offline on a labelled set (see cmd/evalsentiment) and
+// stored next to the model.
+type Calibration struct {
+	Method string `json:"method"`
+	// Temperature divides the logits before the softmax
+	Temperature float64 `json:"temperature,omitempty"`
+	// Isotonic maps each class's softmax probability through a
+	// non-decreasing curve; the results are renormalised to sum to 1
+	Isotonic map[string]IsotonicCurve `json:"isotonic,omitempty"`
+
+	// Provenance of the fit, informational only
+	Model     string    `json:"model,omitempty"`
+	Dataset   string    `json:"dataset,omitempty"`
+	Examples  int       `json:"examples,omitempty"`
+	ECEBefore float64   `json:"ece_before,omitempty"`
+	ECEAfter  float64   `json:"ece_after,omitempty"`
+	FittedAt  time.Time `json:"fitted_at,omitempty"`
+}
+
+// IsotonicCurve is a piecewise-linear, non-decreasing map from
+// uncalibrated to calibrated probability. Inputs outside the knots are
+// clamped to the end values.
+type IsotonicCurve struct {
+	X []float64 `json:"x"`
+	Y []float64 `json:"y"`
+}
+
+// LoadCalibration reads and validates a calibration file
+func LoadCalibration(path string) (*Calibration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Calibration
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid calibration %s: %v", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("calibration %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// Validate checks the calibration can be applied
+func (c *Calibration) Validate() error {
+	switch c.Method {
+	case CalibrationTemperature:
+		if !(c.Temperature > 0) || math.IsInf(c.Temperature, 0) {
+			return fmt.Errorf("temperature must be positive, got %v", c.Temperature)
+		}
+	case CalibrationIsotonic:
+		for _, label := range DefaultLabels {
+			curve, ok := c.Isotonic[label]
+			if !ok {
+				return fmt.Errorf("isotonic curve missing for %s", label)
+			}
+			if len(curve.X) == 0 || len(curve.X) != len(curve.Y) {
+				return fmt.Errorf("isotonic curve for %s needs matching, non-empty x and y", label)
+			}
+			for i := range curve.X {
+				if curve.Y[i] < 0 || curve.Y[i] > 1 {
+					return fmt.Errorf("isotonic curve for %s: y must be within [0, 1]", label)
+				}
+				if i > 0 && (curve.X[i] < curve.X[i-1] || curve.Y[i] < curve.Y[i-1]) {
+					return fmt.Errorf("isotonic curve for %s must be non-decreasing", label)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown calibration method %q", c.Method)
+	}
+	return nil
+}
+
+// Probabilities converts one row of logits, in labels order, into class
+// probabilities. A nil calibration is a plain softmax.
+func (c *Calibration) Probabilities(logits []float64, labels []string) []float64 {
+	temperature := 1.0
+	if c != nil && c.Method == CalibrationTemperature {
+		temperature = c.Temperature
+	}
+	probs := softmax(logits, temperature)
+	if c == nil || c.Method != CalibrationIsotonic {
+		return probs
+	}
+
+	calibrated := make([]float64, len(probs))
+	var sum float64
+	for i, label := range labels {
+		calibrated[i] = c.Isotonic[label].at(probs[i])
+		sum += calibrated[i]
+	}
+	// Every curve mapped to zero: nothing to renormalise, keep the softmax
+	if sum == 0 {
+		return probs
+	}
+	for i := range calibrated {
+		calibrated[i] /= sum
+	}
+	return calibrated
+}
+
+func (curve IsotonicCurve) at(x float64) float64 {
+	n := len(curve.X)
+	if n == 0 {
+		return x
+	}
+	if x <= curve.X[0] {
+		return curve.Y[0]
+	}
+	if x >= curve.X[n-1] {
+		return curve.Y[n-1]
+	}
+	i := sort.SearchFloat64s(curve.X, x)
+	x0, x1 := curve.X[i-1], curve.X[i]
+	y0, y1 := curve.Y[i-1], curve.Y[i]
+	if x1 == x0 {
+		return y1
+	}
+	return y0 + (y1-y0)*(x-x0)/(x1-x0)
+}
+
+func softmax(logits []float64, temperature float64) []float64 {
+	maxLogit := math.Inf(-1)
+	for _, logit := range logits {
+		maxLogit = max(maxLogit, logit)
+	}
+	var sum float64
+	probs := make([]float64, len(logits))
+	for i, logit := range logits {
+		probs[i] = math.Exp((logit - maxLogit) / temperature)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+	return probs
+}
+
+// processLogits calibrates the logits into probabilities and reports the
+// top class, its probability, P(positive) - P(negative), and every class's
+// probability and raw logit
+func processLogits(logits []float32, labels []string, calibration *Calibration) *BERTSentiment {
+	if len(logits) < len(labels) {
+		return &BERTSentiment{
+			Label:      LabelNeutral,
+			Confidence: 0.0,
+			Score:      0.0,
+		}
+	}
+	raw := make([]float64, len(labels))
+	for i := range raw {
+		raw[i] = float64(logits[i])
+	}
+	probs := calibration.Probabilities(raw, labels)
+
+	result := &BERTSentiment{}
+	for i, label := range labels {
+		result.Probabilities.set(label, probs[i])
+		result.Logits.set(label, raw[i])
+		if probs[i] > result.Confidence {
+			result.Confidence = probs[i]
+			result.Label = label
+		}
+	}
+
+	// Sentiment score (-1 to 1): positive - negative
+	result.Score = result.Probabilities.Positive - result.Probabilities.Negative
+	return result
+}
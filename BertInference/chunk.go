@@ -0,0 +1,102 @@
+package BertInference
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Document is a piece of text to score as a whole: an optional headline and
+// a body of any length. Bodies longer than one model window are split into
+// overlapping chunks.
+type Document struct {
+	Title string
+	Body  string
+}
+
+// Segment kinds reported in ChunkSentiment
+const (
+	SegmentHeadline = "headline"
+	SegmentBody     = "body"
+)
+
+// ChunkSentiment is the model output for one window of a document. Start and
+// End are WordPiece token offsets into the segment, and Weight is the share
+// of the document score this chunk contributed.
+type ChunkSentiment struct {
+	BERTSentiment
+	Segment string  `json:"segment"`
+	Start   int     `json:"start"`
+	End     int     `json:"end"`
+	Weight  float64 `json:"weight"`
+}
+
+// DocumentSentiment is the aggregated sentiment of a document, plus the
+// per-chunk scores it was built from
+type DocumentSentiment struct {
+	BERTSentiment
+	Chunks []ChunkSentiment `json:"chunks,omitempty"`
+}
+
+// chunkConfig controls how long documents are split and recombined
+type chunkConfig struct {
+	overlap        int
+	maxChunks      int
+	headlineWeight float64
+}
+
+// chunkConfigFromEnv reads BERT_CHUNK_OVERLAP, BERT_MAX_CHUNKS and
+// BERT_HEADLINE_WEIGHT
+func chunkConfigFromEnv() chunkConfig {
+	cfg := chunkConfig{overlap: 64, maxChunks: 8, headlineWeight: 0.4}
+	if n, err := strconv.Atoi(os.Getenv("BERT_CHUNK_OVERLAP")); err == nil && n >= 0 {
+		cfg.overlap = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BERT_MAX_CHUNKS")); err == nil && n > 0 {
+		cfg.maxChunks = n
+	}
+	if w, err := strconv.ParseFloat(os.Getenv("BERT_HEADLINE_WEIGHT"), 64); err == nil && w >= 0 && w <= 1 {
+		cfg.headlineWeight = w
+	}
+	return cfg
+}
+
+// TokenSpan is a half-open [Start, End) range of token offsets
+type TokenSpan struct {
+	Start int
+	End   int
+}
+
+// ChunkTokens splits tokens into windows of at most size tokens, each
+// starting about overlap tokens before the previous one ended. A window
+// never starts on a "##" continuation piece, so no word loses its beginning
+// at a chunk boundary. maxChunks <= 0 means no limit.
+func ChunkTokens(tokens []string, size, overlap, maxChunks int) []TokenSpan {
+	if len(tokens) == 0 {
+		return nil
+	}
+	if size < 1 {
+		size = 1
+	}
+	if overlap < 0 {
+		overlap = 0
+	}
+	if overlap >= size {
+		overlap = size / 2
+	}
+
+	var spans []TokenSpan
+	for start := 0; ; {
+		end := min(start+size, len(tokens))
+		spans = append(spans, TokenSpan{Start: start, End: end})
+		if end == len(tokens) || (maxChunks > 0 && len(spans) == maxChunks) {
+			return spans
+		}
+
+		next := end - overlap
+		for next > start+1 && strings.HasPrefix(tokens[next], "##") {
+			next--
+		}
+		start = next
+	}
+}
@@ -0,0 +1,129 @@
+//go:build onnx
+// +build onnx
+
+package BertInference
+
+// segment is one model window of a document
+type segment struct {
+	kind   string
+	span   TokenSpan
+	tokens []string
+}
+
+// RunBERTInferenceDocuments scores documents of any length with the default
+// model. See RunModelDocuments.
+func RunBERTInferenceDocuments(docs []Document) ([]*DocumentSentiment, error) {
+	return RunModelDocuments("", docs)
+}
+
+// RunModelDocuments scores documents of any length with the named model
+// ("" for the default). Each headline is one window; each body is split
+// into overlapping windows that fit the model's max length. Every window of
+// every document goes into the same batch, then each document's logits are
+// combined: body chunks are weighted by length and the headline gets a
+// fixed share (BERT_HEADLINE_WEIGHT) of the total.
+func RunModelDocuments(name string, docs []Document) ([]*DocumentSentiment, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	m, cfg, err := acquireModel(name)
+	if err != nil {
+		return nil, err
+	}
+	defer m.release()
+
+	perDoc := make([][]segment, len(docs))
+	var seqs [][]string
+	for i, doc := range docs {
+		perDoc[i] = m.splitDocument(doc, cfg)
+		for _, seg := range perDoc[i] {
+			seqs = append(seqs, seg.tokens)
+		}
+	}
+
+	logits, err := m.batcher.submitAll(seqs, inferenceTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*DocumentSentiment, len(docs))
+	offset := 0
+	for i, segs := range perDoc {
+		results[i] = m.combineSegments(segs, logits[offset:offset+len(segs)], cfg.headlineWeight)
+		offset += len(segs)
+	}
+	return results, nil
+}
+
+// splitDocument tokenizes a document into its headline window and body
+// chunks. A document with no text still yields one empty window so every
+// document gets a result.
+func (m *model) splitDocument(doc Document, cfg chunkConfig) []segment {
+	window := m.spec.MaxLength - 2
+	var segs []segment
+
+	if title := m.tokenizer.Tokenize(doc.Title); len(title) > 0 {
+		end := min(len(title), window)
+		segs = append(segs, segment{kind: SegmentHeadline, span: TokenSpan{End: end}, tokens: title[:end]})
+	}
+
+	body := m.tokenizer.Tokenize(doc.Body)
+	for _, span := range ChunkTokens(body, window, cfg.overlap, cfg.maxChunks) {
+		segs = append(segs, segment{kind: SegmentBody, span: span, tokens: body[span.Start:span.End]})
+	}
+
+	if len(segs) == 0 {
+		segs = append(segs, segment{kind: SegmentBody})
+	}
+	return segs
+}
+
+// combineSegments averages the segment logits with the headline holding
+// headlineWeight of the total and body chunks sharing the rest by length.
+// Without a body the headline takes all the weight, and vice versa.
+func (m *model) combineSegments(segs []segment, logits [][]float32, headlineWeight float64) *DocumentSentiment {
+	var bodyTokens int
+	hasHeadline := false
+	for _, seg := range segs {
+		if seg.kind == SegmentHeadline {
+			hasHeadline = true
+		} else {
+			bodyTokens += max(1, len(seg.tokens))
+		}
+	}
+	switch {
+	case bodyTokens == 0:
+		headlineWeight = 1
+	case !hasHeadline:
+		headlineWeight = 0
+	}
+
+	combined := make([]float64, len(m.labels))
+	chunks := make([]ChunkSentiment, len(segs))
+	for i, seg := range segs {
+		weight := headlineWeight
+		if seg.kind == SegmentBody {
+			weight = (1 - headlineWeight) * float64(max(1, len(seg.tokens))) / float64(bodyTokens)
+		}
+		for j := range combined {
+			combined[j] += weight * float64(logits[i][j])
+		}
+		chunks[i] = ChunkSentiment{
+			BERTSentiment: *m.sentiment(logits[i]),
+			Segment:       seg.kind,
+			Start:         seg.span.Start,
+			End:           seg.span.End,
+			Weight:        weight,
+		}
+	}
+
+	avg := make([]float32, len(combined))
+	for j, v := range combined {
+		avg[j] = float32(v)
+	}
+	return &DocumentSentiment{
+		BERTSentiment: *m.sentiment(avg),
+		Chunks:        chunks,
+	}
+}
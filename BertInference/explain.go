@@ -0,0 +1,107 @@
+package BertInference
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// MethodOcclusion is the attribution method reported in Explanation
+const MethodOcclusion = "occlusion"
+
+// Explanation attributes a prediction to the tokens of the text. Each
+// token is replaced in turn by Baseline and the text scored again; the
+// token's contribution to a class is how much the class probability drops
+// without it. A positive value means the token pushed towards the class.
+type Explanation struct {
+	// BERTSentiment is the prediction being explained: the explained
+	// tokens scored as one window, without a headline
+	BERTSentiment
+	Method   string             `json:"method"`
+	Baseline string             `json:"baseline"`
+	Tokens   []TokenAttribution `json:"tokens"`
+	// Truncated is set when the text had more tokens than were explained
+	Truncated bool `json:"truncated"`
+}
+
+// TokenAttribution is one token with its contribution to each class
+// probability
+type TokenAttribution struct {
+	Token         string      `json:"token"`
+	Contributions ClassScores `json:"contributions"`
+}
+
+// explainMaxTokens reads BERT_EXPLAIN_MAX_TOKENS, the most tokens one
+// explanation occludes. Each costs one more model run.
+func explainMaxTokens() int {
+	if n, err := strconv.Atoi(os.Getenv("BERT_EXPLAIN_MAX_TOKENS")); err == nil && n > 0 {
+		return n
+	}
+	return 64
+}
+
+// explainTimeout reads BERT_EXPLAIN_TIMEOUT_MS, how long one explanation
+// may take in total. It replaces the timeout of ordinary requests, which
+// a long text's occlusions could exceed.
+func explainTimeout() time.Duration {
+	if ms, err := strconv.Atoi(os.Getenv("BERT_EXPLAIN_TIMEOUT_MS")); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return time.Minute
+}
+
+// occlusions returns tokens followed by one copy per token with that token
+// replaced by baseline
+func occlusions(tokens []string, baseline string) [][]string {
+	seqs := make([][]string, 0, len(tokens)+1)
+	seqs = append(seqs, tokens)
+	for i := range tokens {
+		occluded := slices.Clone(tokens)
+		occluded[i] = baseline
+		seqs = append(seqs, occluded)
+	}
+	return seqs
+}
+
+// newExplanation builds an explanation from the sentiment of the full
+// tokens and of each occlusion, in the order returned by occlusions
+func newExplanation(tokens []string, baseline string, truncated bool, scored []*BERTSentiment) *Explanation {
+	full := scored[0]
+	attributions := make([]TokenAttribution, len(tokens))
+	for i, token := range tokens {
+		occluded := scored[i+1].Probabilities
+		attributions[i] = TokenAttribution{
+			Token: token,
+			Contributions: ClassScores{
+				Positive: full.Probabilities.Positive - occluded.Positive,
+				Neutral:  full.Probabilities.Neutral - occluded.Neutral,
+				Negative: full.Probabilities.Negative - occluded.Negative,
+			},
+		}
+	}
+	return &Explanation{
+		BERTSentiment: *full,
+		Method:        MethodOcclusion,
+		Baseline:      baseline,
+		Tokens:        attributions,
+		Truncated:     truncated,
+	}
+}
+
+// Explain attributes the lexicon's prediction for text to its words, up to
+// maxTokens of them
+func (l *Lexicon) Explain(text string, maxTokens int) *Explanation {
+	words := lexiconWords(text)
+	truncated := len(words) > maxTokens
+	if truncated {
+		words = words[:maxTokens]
+	}
+
+	seqs := occlusions(words, maskToken)
+	scored := make([]*BERTSentiment, len(seqs))
+	for i, seq := range seqs {
+		scored[i] = l.sentiment(l.logits(seq))
+	}
+	return newExplanation(words, maskToken, truncated, scored)
+}
@@ -0,0 +1,52 @@
+//go:build onnx
+// +build onnx
+
+package BertInference
+
+import (
+	"fmt"
+	"time"
+)
+
+// ExplainModel attributes the named model's ("" for the default)
+// prediction for text to its WordPiece tokens by occlusion. Only the first
+// window is explained, up to BERT_EXPLAIN_MAX_TOKENS tokens. The full text
+// and the occlusions go to the model one batch at a time, so other
+// requests are not queued behind the whole explanation.
+func ExplainModel(name, text string) (*Explanation, error) {
+	m, _, err := acquireModel(name)
+	if err != nil {
+		return nil, err
+	}
+	defer m.release()
+
+	tokens := m.tokenizer.Tokenize(text)
+	limit := min(m.spec.MaxLength-2, explainMaxTokens())
+	truncated := len(tokens) > limit
+	if truncated {
+		tokens = tokens[:limit]
+	}
+
+	baseline := m.tokenizer.occlusionToken()
+	seqs := occlusions(tokens, baseline)
+	timeout := explainTimeout()
+	deadline := time.Now().Add(timeout)
+	logits := make([][]float32, 0, len(seqs))
+	for start := 0; start < len(seqs); start += m.batcher.maxBatch {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, fmt.Errorf("explanation timeout after %s", timeout)
+		}
+		part, err := m.batcher.submitAll(seqs[start:min(start+m.batcher.maxBatch, len(seqs))], remaining)
+		if err != nil {
+			return nil, err
+		}
+		logits = append(logits, part...)
+	}
+
+	scored := make([]*BERTSentiment, len(logits))
+	for i := range logits {
+		scored[i] = m.sentiment(logits[i])
+	}
+	return newExplanation(tokens, baseline, truncated, scored), nil
+}
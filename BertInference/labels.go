@@ -0,0 +1,106 @@
+package BertInference
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Sentiment classes the model can predict
+const (
+	LabelNegative = "negative"
+	LabelNeutral  = "neutral"
+	LabelPositive = "positive"
+)
+
+// DefaultLabels is the logits order assumed when the model config does not
+// provide id2label
+var DefaultLabels = []string{LabelNegative, LabelNeutral, LabelPositive}
+
+// ClassScores holds one value per sentiment class
+type ClassScores struct {
+	Positive float64 `json:"positive"`
+	Neutral  float64 `json:"neutral"`
+	Negative float64 `json:"negative"`
+}
+
+// Get returns the value for a class label
+func (s ClassScores) Get(label string) float64 {
+	switch label {
+	case LabelPositive:
+		return s.Positive
+	case LabelNeutral:
+		return s.Neutral
+	case LabelNegative:
+		return s.Negative
+	}
+	return 0
+}
+
+func (s *ClassScores) set(label string, v float64) {
+	switch label {
+	case LabelPositive:
+		s.Positive = v
+	case LabelNeutral:
+		s.Neutral = v
+	case LabelNegative:
+		s.Negative = v
+	}
+}
+
+// LoadLabels reads the logits order from the id2label map of a HuggingFace
+// config.json
+func LoadLabels(configPath string) ([]string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		ID2Label map[string]string `json:"id2label"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid model config %s: %v", configPath, err)
+	}
+
+	names := make([]string, len(config.ID2Label))
+	for id, name := range config.ID2Label {
+		i, err := strconv.Atoi(id)
+		if err != nil || i < 0 || i >= len(names) || names[i] != "" {
+			return nil, fmt.Errorf("model config %s: invalid label id %q", configPath, id)
+		}
+		names[i] = name
+	}
+	labels, err := ParseLabels(names)
+	if err != nil {
+		return nil, fmt.Errorf("model config %s: %v", configPath, err)
+	}
+	return labels, nil
+}
+
+// ParseLabels validates a logits order: every class must appear exactly
+// once. Labels are matched case-insensitively.
+func ParseLabels(names []string) ([]string, error) {
+	if len(names) != len(DefaultLabels) {
+		return nil, fmt.Errorf("expected %d labels, got %d", len(DefaultLabels), len(names))
+	}
+
+	labels := make([]string, len(names))
+	seen := make(map[string]bool, len(names))
+	for i, name := range names {
+		label := strings.ToLower(strings.TrimSpace(name))
+		switch label {
+		case LabelNegative, LabelNeutral, LabelPositive:
+		default:
+			return nil, fmt.Errorf("unknown label %q", name)
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("duplicate label %q", name)
+		}
+		seen[label] = true
+		labels[i] = label
+	}
+	return labels, nil
+}
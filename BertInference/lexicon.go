@@ -0,0 +1,204 @@
+package BertInference
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Engines that can produce a BERTSentiment
+const (
+	EngineONNX    = "onnx"
+	EngineLexicon = "lexicon"
+)
+
+//go:embed lexicon/positive.txt lexicon/negative.txt
+var lexiconFiles embed.FS
+
+// negationWindow is how many words before a sentiment word a negation
+// flips it
+const negationWindow = 3
+
+var negations = map[string]bool{
+	"no": true, "not": true, "none": true, "neither": true, "nor": true,
+	"never": true, "nobody": true, "nothing": true, "without": true, "cannot": true,
+}
+
+// Lexicon is a dictionary sentiment scorer used when the ONNX runtime is
+// not built in. It counts positive and negative words, flipping those
+// preceded by a negation within three words, and turns the counts into
+// logits so results follow the same contract as the model.
+type Lexicon struct {
+	Name    string
+	Version string
+	Source  string
+	// Fingerprint is a hash of the word lists
+	Fingerprint string
+	positive    map[string]bool
+	negative    map[string]bool
+}
+
+// LoadLexicon reads positive.txt and negative.txt (one lowercase word per
+// line, # comments) from dir, or the built-in lists when dir is empty
+func LoadLexicon(dir string) (*Lexicon, error) {
+	lex := &Lexicon{Name: EngineLexicon, Version: "1.0", Source: "built-in"}
+	var files fs.FS = lexiconFiles
+	prefix := "lexicon/"
+	if dir != "" {
+		files, prefix = os.DirFS(dir), ""
+		lex.Version, lex.Source = "custom", dir
+	}
+
+	var err error
+	if lex.positive, err = readWordList(files, prefix+"positive.txt"); err != nil {
+		return nil, err
+	}
+	if lex.negative, err = readWordList(files, prefix+"negative.txt"); err != nil {
+		return nil, err
+	}
+	words := append(slices.Sorted(maps.Keys(lex.positive)), "")
+	words = append(words, slices.Sorted(maps.Keys(lex.negative))...)
+	if lex.Fingerprint, err = fingerprint(nil, words...); err != nil {
+		return nil, err
+	}
+	return lex, nil
+}
+
+func readWordList(files fs.FS, name string) (map[string]bool, error) {
+	file, err := files.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseWordList(file, name)
+}
+
+func parseWordList(r io.Reader, name string) (map[string]bool, error) {
+	words := make(map[string]bool)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words[word] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("%s: no words", name)
+	}
+	return words, nil
+}
+
+// ID is the name@version recorded with every result
+func (l *Lexicon) ID() string {
+	return l.Name + "@" + l.Version
+}
+
+// lexiconWords lowercases text and splits it into words, keeping
+// apostrophes so "isn't" stays one word
+func lexiconWords(text string) []string {
+	text = strings.ReplaceAll(strings.ToLower(text), "’", "'")
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	})
+	for i, w := range words {
+		words[i] = strings.TrimSuffix(strings.Trim(w, "'"), "'s")
+	}
+	return words
+}
+
+// logits turns word counts into [negative, neutral, positive] logits. Each
+// hit adds 1.5 to its class; the neutral logit grows with the log of the
+// length so one stray word does not swing a long article.
+func (l *Lexicon) logits(words []string) []float32 {
+	var positive, negative float64
+	lastNegation := -negationWindow - 1
+	for i, w := range words {
+		if negations[w] || strings.HasSuffix(w, "n't") {
+			lastNegation = i
+			continue
+		}
+		hit := 0.0
+		switch {
+		case l.positive[w]:
+			hit = 1
+		case l.negative[w]:
+			hit = -1
+		default:
+			continue
+		}
+		if i-lastNegation <= negationWindow {
+			hit = -hit
+		}
+		if hit > 0 {
+			positive++
+		} else {
+			negative++
+		}
+	}
+
+	neutral := 0.75 + 0.5*math.Log1p(float64(len(words))/20)
+	return []float32{float32(1.5 * negative), float32(neutral), float32(1.5 * positive)}
+}
+
+func (l *Lexicon) sentiment(logits []float32) *BERTSentiment {
+	result := processLogits(logits, DefaultLabels, nil)
+	result.Model = l.ID()
+	result.Engine = EngineLexicon
+	return result
+}
+
+// Score scores one text
+func (l *Lexicon) Score(text string) *BERTSentiment {
+	return l.sentiment(l.logits(lexiconWords(text)))
+}
+
+// ScoreDocument scores the headline and body separately and combines their
+// logits with the headline holding headlineWeight, as the model does.
+// Chunk offsets are word offsets.
+func (l *Lexicon) ScoreDocument(doc Document, headlineWeight float64) *DocumentSentiment {
+	title, body := lexiconWords(doc.Title), lexiconWords(doc.Body)
+	switch {
+	case len(body) == 0:
+		headlineWeight = 1
+	case len(title) == 0:
+		headlineWeight = 0
+	}
+
+	combined := make([]float32, len(DefaultLabels))
+	var chunks []ChunkSentiment
+	add := func(kind string, words []string, weight float64) {
+		logits := l.logits(words)
+		for j := range combined {
+			combined[j] += float32(weight) * logits[j]
+		}
+		chunks = append(chunks, ChunkSentiment{
+			BERTSentiment: *l.sentiment(logits),
+			Segment:       kind,
+			End:           len(words),
+			Weight:        weight,
+		})
+	}
+	if headlineWeight > 0 {
+		add(SegmentHeadline, title, headlineWeight)
+	}
+	if headlineWeight < 1 {
+		add(SegmentBody, body, 1-headlineWeight)
+	}
+
+	return &DocumentSentiment{
+		BERTSentiment: *l.sentiment(combined),
+		Chunks:        chunks,
+	}
+}
@@ -0,0 +1,64 @@
+//go:build onnx
+// +build onnx
+
+package BertInference
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	queueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bert_queue_depth",
+			Help: "Inference requests waiting to be batched",
+		},
+		[]string{"model"},
+	)
+
+	queueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bert_queue_wait_seconds",
+			Help:    "Time from queuing a request until its batch starts on a session",
+			Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		},
+		[]string{"model"},
+	)
+
+	batchSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bert_batch_size",
+			Help:    "Number of texts per model run",
+			Buckets: []float64{1, 2, 4, 8, 16, 32, 64},
+		},
+		[]string{"model"},
+	)
+
+	batchDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bert_batch_duration_seconds",
+			Help:    "Model run time per batch",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"model"},
+	)
+
+	sessionBusy = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bert_session_busy",
+			Help: "Whether an ONNX session is currently running a batch (1) or idle (0)",
+		},
+		[]string{"model", "session"},
+	)
+
+	// rate(bert_session_busy_seconds_total[1m]) gives per-session utilisation
+	sessionBusySeconds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bert_session_busy_seconds_total",
+			Help: "Cumulative time each ONNX session spent running batches",
+		},
+		[]string{"model", "session"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(queueDepth, queueWait, batchSize, batchDuration, sessionBusy, sessionBusySeconds)
+}
@@ -0,0 +1,233 @@
+//go:build onnx
+// +build onnx
+
+package BertInference
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+var (
+	modelInputNames  = []string{"input_ids", "attention_mask", "token_type_ids"}
+	modelOutputNames = []string{"logits"}
+)
+
+// model is one loaded ONNX model with its own sessions, batcher, tokenizer
+// and label order
+type model struct {
+	spec      ModelSpec
+	tokenizer *Tokenizer
+	labels    []string
+	// calibration is nil when the model has none
+	calibration *Calibration
+	fingerprint string
+	pool        *sessionPool
+	batcher     *batcher
+	loadedAt    time.Time
+
+	// users counts requests holding the model so a reload only closes it
+	// once they are done
+	users sync.WaitGroup
+}
+
+// loadModel reads the vocab and label order, creates the session pool and
+// starts the batcher for one manifest entry
+func loadModel(spec ModelSpec) (*model, error) {
+	vocab, err := LoadVocab(spec.Vocab)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: failed to load vocab: %v", spec.Name, err)
+	}
+
+	labels, err := modelLabels(spec)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %v", spec.Name, err)
+	}
+
+	var calibration *Calibration
+	calibrationMethod := "none"
+	if spec.Calibration != "" {
+		calibration, err = LoadCalibration(spec.Calibration)
+		if err != nil {
+			return nil, fmt.Errorf("model %s: failed to load calibration: %v", spec.Name, err)
+		}
+		calibrationMethod = calibration.Method
+	}
+
+	sum, err := fingerprint([]string{spec.Path, spec.Vocab, spec.Config, spec.Calibration}, labels...)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: failed to fingerprint: %v", spec.Name, err)
+	}
+
+	poolSize, intraOpThreads := sessionPoolConfigFromEnv()
+	pool, err := newSessionPool(spec.ID(), spec.Path, modelInputNames, modelOutputNames, poolSize, intraOpThreads)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %v", spec.Name, err)
+	}
+
+	m := &model{
+		spec:        spec,
+		tokenizer:   NewTokenizer(vocab),
+		labels:      labels,
+		calibration: calibration,
+		fingerprint: sum,
+		pool:        pool,
+		loadedAt:    time.Now(),
+	}
+
+	maxBatch, maxWait := batcherConfigFromEnv()
+	m.batcher = newBatcher(spec.ID(), maxBatch, maxWait, pool, m.runBatch)
+	m.batcher.start()
+
+	log.Printf("Loaded model %s from %s: %d sessions, %d intra-op threads each, batch %d/%s, labels %s, calibration %s",
+		spec.ID(), spec.Path, poolSize, intraOpThreads, maxBatch, maxWait, strings.Join(labels, ", "), calibrationMethod)
+	return m, nil
+}
+
+// modelLabels uses the manifest's labels, then the model config's id2label,
+// then the default order. A config that exists but is invalid is an error.
+func modelLabels(spec ModelSpec) ([]string, error) {
+	if spec.Labels != nil {
+		return spec.Labels, nil
+	}
+	if !fileExists(spec.Config) {
+		log.Printf("No model config at %s, assuming label order %s", spec.Config, strings.Join(DefaultLabels, ", "))
+		return DefaultLabels, nil
+	}
+	labels, err := LoadLabels(spec.Config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load labels: %v", err)
+	}
+	return labels, nil
+}
+
+func (m *model) info() ModelInfo {
+	return ModelInfo{
+		Name:        m.spec.Name,
+		Version:     m.spec.Version,
+		ID:          m.spec.ID(),
+		Path:        m.spec.Path,
+		Labels:      m.labels,
+		MaxLength:   m.spec.MaxLength,
+		Calibration: m.calibrationMethod(),
+		Fingerprint: m.fingerprint,
+		Engine:      EngineONNX,
+		Sessions:    m.pool.size(),
+		LoadedAt:    m.loadedAt,
+	}
+}
+
+func (m *model) calibrationMethod() string {
+	if m.calibration == nil {
+		return ""
+	}
+	return m.calibration.Method
+}
+
+func (m *model) release() {
+	m.users.Done()
+}
+
+// close waits for requests still using the model, then stops the batcher
+// and frees the sessions
+func (m *model) close() {
+	m.users.Wait()
+	m.batcher.close()
+	m.pool.destroy()
+	log.Printf("Unloaded model %s", m.spec.ID())
+}
+
+// sentiment turns one row of logits into a result tagged with this model
+func (m *model) sentiment(logits []float32) *BERTSentiment {
+	result := processLogits(logits, m.labels, m.calibration)
+	result.Model = m.spec.ID()
+	result.Engine = EngineONNX
+	return result
+}
+
+// infer scores texts, each truncated to one model window
+func (m *model) infer(texts []string) ([]*BERTSentiment, error) {
+	seqs := make([][]string, len(texts))
+	for i, text := range texts {
+		seqs[i] = m.tokenizer.Tokenize(text)
+	}
+	logits, err := m.batcher.submitAll(seqs, inferenceTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*BERTSentiment, len(logits))
+	for i := range logits {
+		results[i] = m.sentiment(logits[i])
+	}
+	return results, nil
+}
+
+// runBatch encodes the token sequences into [N, max_length] tensors, runs
+// the session once and splits the [N, 3] logits back into one slice per
+// sequence.
+func (m *model) runBatch(session *ort.DynamicAdvancedSession, seqs [][]string) ([][]float32, error) {
+	n := len(seqs)
+	maxLen := m.spec.MaxLength
+	inputIds := make([]int64, 0, n*maxLen)
+	attentionMask := make([]int64, 0, n*maxLen)
+	tokenTypeIds := make([]int64, 0, n*maxLen)
+	for _, tokens := range seqs {
+		ids, mask, types := m.tokenizer.Encode(tokens, maxLen)
+		inputIds = append(inputIds, ids...)
+		attentionMask = append(attentionMask, mask...)
+		tokenTypeIds = append(tokenTypeIds, types...)
+	}
+
+	inputShape := ort.NewShape(int64(n), int64(maxLen))
+
+	inputTensor, err := ort.NewTensor(inputShape, inputIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create input tensor: %v", err)
+	}
+	defer inputTensor.Destroy()
+
+	attentionTensor, err := ort.NewTensor(inputShape, attentionMask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attention tensor: %v", err)
+	}
+	defer attentionTensor.Destroy()
+
+	tokenTypeTensor, err := ort.NewTensor(inputShape, tokenTypeIds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token type tensor: %v", err)
+	}
+	defer tokenTypeTensor.Destroy()
+
+	classes := int64(len(m.labels))
+	outputTensor, err := ort.NewEmptyTensor[float32](ort.NewShape(int64(n), classes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output tensor: %v", err)
+	}
+	defer outputTensor.Destroy()
+
+	inputs := []ort.Value{inputTensor, attentionTensor, tokenTypeTensor}
+	outputs := []ort.Value{outputTensor}
+
+	if err := session.Run(inputs, outputs); err != nil {
+		return nil, fmt.Errorf("failed to run inference: %v", err)
+	}
+
+	logits := outputTensor.GetData()
+	if int64(len(logits)) < int64(n)*classes {
+		return nil, fmt.Errorf("insufficient logits: got %d, expected %d", len(logits), int64(n)*classes)
+	}
+
+	// Copy out of the tensor, which is destroyed on return
+	k := int(classes)
+	results := make([][]float32, n)
+	for i := range results {
+		results[i] = append([]float32(nil), logits[i*k:i*k+k]...)
+	}
+	return results, nil
+}
@@ -3,25 +3,178 @@
 
 package BertInference
 
-import "fmt"
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
 
-// Stub implementations when ONNX runtime isn't available. These return
-// sensible errors so the main app continues running without BERT features.
+// Without the ONNX runtime, sentiment is scored by the pure-Go lexicon
+// fallback (see lexicon.go). Results follow the same contract and report
+// engine "lexicon". LEXICON_DIR replaces the built-in word lists.
 
 type BERTSentiment struct {
-	Label      string  `json:"label"`
-	Confidence float64 `json:"confidence"`
-	Score      float64 `json:"score"`
+	Label         string      `json:"label"`
+	Confidence    float64     `json:"confidence"`
+	Score         float64     `json:"score"`
+	Probabilities ClassScores `json:"probabilities"`
+	Logits        ClassScores `json:"logits"`
+	// Model is the name@version that produced the result
+	Model string `json:"model"`
+	// Engine is "onnx" for the model or "lexicon" for the fallback
+	Engine string `json:"engine"`
 }
 
-func InitializeBERT(modelPath, vocabPath string) error {
-	return fmt.Errorf("ONNX runtime disabled: build with -tags=onnx to enable BERT")
+// fallback is the loaded lexicon with the settings read alongside it
+type fallback struct {
+	lexicon        *Lexicon
+	headlineWeight float64
+	loadedAt       time.Time
+}
+
+var (
+	fallbackMutex  sync.RWMutex
+	activeFallback *fallback
+)
+
+// InitializeBERT loads the lexicon fallback; the manifest is ignored
+func InitializeBERT(manifestPath string) error {
+	f, err := loadLexicon()
+	if err != nil {
+		return err
+	}
+	lex := f.lexicon
+	log.Printf("ONNX runtime disabled (build with -tags=onnx for FinBERT): scoring sentiment with the %s lexicon (%s, %d positive, %d negative words)",
+		lex.ID(), lex.Source, len(lex.positive), len(lex.negative))
+	return nil
+}
+
+// loadLexicon reads the word lists from LEXICON_DIR (or the built-in
+// ones) and BERT_HEADLINE_WEIGHT, and swaps them in
+func loadLexicon() (*fallback, error) {
+	lex, err := LoadLexicon(os.Getenv("LEXICON_DIR"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load lexicon: %v", err)
+	}
+	f := &fallback{
+		lexicon:        lex,
+		headlineWeight: chunkConfigFromEnv().headlineWeight,
+		loadedAt:       time.Now(),
+	}
+	fallbackMutex.Lock()
+	activeFallback = f
+	fallbackMutex.Unlock()
+	return f, nil
+}
+
+// current returns the loaded lexicon, loading it on first use when
+// InitializeBERT was not called
+func current() (*fallback, error) {
+	fallbackMutex.RLock()
+	f := activeFallback
+	fallbackMutex.RUnlock()
+	if f != nil {
+		return f, nil
+	}
+	return loadLexicon()
+}
+
+// IsInitialized is always true: the lexicon fallback needs no setup
+func IsInitialized() bool {
+	return true
 }
 
 func CleanupBERT() {
 	// no-op
 }
 
-func RunBERTInference(text string, modelPath string) (*BERTSentiment, error) {
-	return nil, fmt.Errorf("ONNX runtime disabled: build with -tags=onnx to enable BERT")
+func RunBERTInference(text string) (*BERTSentiment, error) {
+	f, err := current()
+	if err != nil {
+		return nil, err
+	}
+	return f.lexicon.Score(text), nil
+}
+
+func RunBERTInferenceBatch(texts []string) ([]*BERTSentiment, error) {
+	f, err := current()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*BERTSentiment, len(texts))
+	for i, text := range texts {
+		results[i] = f.lexicon.Score(text)
+	}
+	return results, nil
+}
+
+func RunBERTInferenceDocuments(docs []Document) ([]*DocumentSentiment, error) {
+	return RunModelDocuments("", docs)
+}
+
+func RunModelDocuments(name string, docs []Document) ([]*DocumentSentiment, error) {
+	f, err := current()
+	if err != nil {
+		return nil, err
+	}
+	if name != "" && name != f.lexicon.Name {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModel, name)
+	}
+	results := make([]*DocumentSentiment, len(docs))
+	for i, doc := range docs {
+		results[i] = f.lexicon.ScoreDocument(doc, f.headlineWeight)
+	}
+	return results, nil
+}
+
+// ExplainModel attributes the lexicon's prediction for text to its words
+// by occlusion, up to BERT_EXPLAIN_MAX_TOKENS words
+func ExplainModel(name, text string) (*Explanation, error) {
+	f, err := current()
+	if err != nil {
+		return nil, err
+	}
+	if name != "" && name != f.lexicon.Name {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownModel, name)
+	}
+	return f.lexicon.Explain(text, explainMaxTokens()), nil
+}
+
+// ProcessLogits turns one row of logits in the default label order into a
+// sentiment
+func ProcessLogits(logits []float32) *BERTSentiment {
+	return processLogits(logits, DefaultLabels, nil)
+}
+
+// ReloadModels re-reads the lexicon word lists and headline weight
+func ReloadModels(force bool) ([]ModelInfo, error) {
+	if _, err := loadLexicon(); err != nil {
+		return nil, err
+	}
+	return Models(), nil
+}
+
+func Models() []ModelInfo {
+	f, err := current()
+	if err != nil {
+		return nil
+	}
+	lex := f.lexicon
+	return []ModelInfo{{
+		Name:        lex.Name,
+		Version:     lex.Version,
+		ID:          lex.ID(),
+		Path:        lex.Source,
+		Labels:      DefaultLabels,
+		Engine:      EngineLexicon,
+		Fingerprint: lex.Fingerprint,
+		Default:     true,
+		LoadedAt:    f.loadedAt,
+	}}
+}
+
+func ShadowModels() []ModelInfo {
+	return nil
 }
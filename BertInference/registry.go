@@ -0,0 +1,189 @@
+package BertInference
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrUnknownModel is returned when inference asks for a model that is not
+// in the registry
+var ErrUnknownModel = errors.New("unknown model")
+
+// defaultMaxLength is the sequence length models are exported with unless
+// the manifest says otherwise
+const defaultMaxLength = 256
+
+// ModelSpec describes one ONNX sentiment model in the manifest. Relative
+// paths are resolved against the manifest's directory.
+type ModelSpec struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Path    string `json:"path"`
+	Vocab   string `json:"vocab"`
+	// Config is a HuggingFace config.json whose id2label gives the logits
+	// order; defaults to config.json next to the vocab
+	Config string `json:"config,omitempty"`
+	// Labels overrides the config's label order when set
+	Labels []string `json:"labels,omitempty"`
+	// Calibration is a calibration file fitted for this model; without one
+	// probabilities are the plain softmax
+	Calibration string `json:"calibration,omitempty"`
+	MaxLength   int    `json:"max_length,omitempty"`
+}
+
+// ID is the name@version string recorded with every result
+func (s ModelSpec) ID() string {
+	return s.Name + "@" + s.Version
+}
+
+// Manifest lists the models to load, which one serves requests that do not
+// name a model, and which candidates shadow it
+type Manifest struct {
+	Default string      `json:"default"`
+	Models  []ModelSpec `json:"models"`
+	// Shadow models score the same articles as the default in the
+	// background so they can be compared before promotion
+	Shadow []string `json:"shadow,omitempty"`
+}
+
+// ModelInfo describes a loaded model
+type ModelInfo struct {
+	Name      string   `json:"name"`
+	Version   string   `json:"version"`
+	ID        string   `json:"id"`
+	Path      string   `json:"path"`
+	Labels    []string `json:"labels"`
+	MaxLength int      `json:"max_length"`
+	// Calibration is the calibration method applied, empty for none
+	Calibration string `json:"calibration,omitempty"`
+	// Fingerprint is a hash of what the model was loaded from (weights,
+	// vocab, config, calibration, labels), so new files under the same
+	// name@version are told apart
+	Fingerprint string `json:"fingerprint"`
+	// Engine is "onnx" or "lexicon"
+	Engine   string    `json:"engine"`
+	Sessions int       `json:"sessions"`
+	Default  bool      `json:"default"`
+	Shadow   bool      `json:"shadow"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// LoadManifest reads and validates a model manifest. The first model is the
+// default when none is named.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid model manifest %s: %v", path, err)
+	}
+	if len(manifest.Models) == 0 {
+		return nil, fmt.Errorf("model manifest %s lists no models", path)
+	}
+
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool, len(manifest.Models))
+	for i := range manifest.Models {
+		spec := &manifest.Models[i]
+		if spec.Name == "" || spec.Version == "" || spec.Path == "" || spec.Vocab == "" {
+			return nil, fmt.Errorf("model manifest %s: model %d needs name, version, path and vocab", path, i)
+		}
+		if seen[spec.Name] {
+			return nil, fmt.Errorf("model manifest %s: duplicate model %q", path, spec.Name)
+		}
+		seen[spec.Name] = true
+		// model_version is VARCHAR(50)
+		if len(spec.ID()) > 50 {
+			return nil, fmt.Errorf("model manifest %s: %q is longer than 50 characters", path, spec.ID())
+		}
+
+		if spec.MaxLength == 0 {
+			spec.MaxLength = defaultMaxLength
+		}
+		if spec.MaxLength < 3 {
+			return nil, fmt.Errorf("model manifest %s: model %q max_length must be at least 3", path, spec.Name)
+		}
+		if spec.Labels != nil {
+			labels, err := ParseLabels(spec.Labels)
+			if err != nil {
+				return nil, fmt.Errorf("model manifest %s: model %q: %v", path, spec.Name, err)
+			}
+			spec.Labels = labels
+		}
+
+		spec.Path = resolvePath(dir, spec.Path)
+		spec.Vocab = resolvePath(dir, spec.Vocab)
+		if spec.Config == "" {
+			spec.Config = filepath.Join(filepath.Dir(spec.Vocab), "config.json")
+		} else {
+			spec.Config = resolvePath(dir, spec.Config)
+		}
+		if spec.Calibration != "" {
+			spec.Calibration = resolvePath(dir, spec.Calibration)
+		}
+	}
+
+	if manifest.Default == "" {
+		manifest.Default = manifest.Models[0].Name
+	} else if !seen[manifest.Default] {
+		return nil, fmt.Errorf("model manifest %s: default model %q is not listed", path, manifest.Default)
+	}
+
+	shadows := make(map[string]bool, len(manifest.Shadow))
+	for _, name := range manifest.Shadow {
+		switch {
+		case !seen[name]:
+			return nil, fmt.Errorf("model manifest %s: shadow model %q is not listed", path, name)
+		case name == manifest.Default:
+			return nil, fmt.Errorf("model manifest %s: default model %q cannot shadow itself", path, name)
+		case shadows[name]:
+			return nil, fmt.Errorf("model manifest %s: shadow model %q listed twice", path, name)
+		}
+		shadows[name] = true
+	}
+	return &manifest, nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// fingerprint hashes the contents of files, then parts, into a short hex
+// string. Empty paths and missing files count as empty.
+func fingerprint(files []string, parts ...string) (string, error) {
+	h := sha256.New()
+	for _, path := range files {
+		if path != "" {
+			file, err := os.Open(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return "", err
+			}
+			if err == nil {
+				_, err = io.Copy(h, file)
+				file.Close()
+				if err != nil {
+					return "", err
+				}
+			}
+		}
+		h.Write([]byte{0})
+	}
+	for _, part := range parts {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
@@ -0,0 +1,102 @@
+//go:build onnx
+// +build onnx
+
+package BertInference
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// pooledSession is one ONNX session plus the label it reports metrics under
+type pooledSession struct {
+	id      string
+	session *ort.DynamicAdvancedSession
+}
+
+// sessionPool hands out a fixed set of sessions so batches can run in
+// parallel. Each session loads its own copy of the model, so pool size
+// trades memory for throughput.
+type sessionPool struct {
+	model    string
+	sessions []*pooledSession
+	free     chan *pooledSession
+}
+
+// sessionPoolConfigFromEnv reads BERT_SESSION_POOL_SIZE and
+// BERT_INTRA_OP_THREADS. By default the CPU cores are split evenly
+// between sessions.
+func sessionPoolConfigFromEnv() (size, intraOpThreads int) {
+	size = 1
+	if n, err := strconv.Atoi(os.Getenv("BERT_SESSION_POOL_SIZE")); err == nil && n > 0 {
+		size = n
+	}
+	intraOpThreads = max(1, runtime.NumCPU()/size)
+	if n, err := strconv.Atoi(os.Getenv("BERT_INTRA_OP_THREADS")); err == nil && n > 0 {
+		intraOpThreads = n
+	}
+	return size, intraOpThreads
+}
+
+func newSessionPool(model, modelPath string, inputNames, outputNames []string, size, intraOpThreads int) (*sessionPool, error) {
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session options: %v", err)
+	}
+	defer options.Destroy()
+
+	if err := options.SetIntraOpNumThreads(intraOpThreads); err != nil {
+		return nil, fmt.Errorf("failed to set intra-op threads: %v", err)
+	}
+	// Parallelism comes from the pool; keep each session's graph execution sequential
+	if err := options.SetInterOpNumThreads(1); err != nil {
+		return nil, fmt.Errorf("failed to set inter-op threads: %v", err)
+	}
+
+	pool := &sessionPool{model: model, free: make(chan *pooledSession, size)}
+	for i := 0; i < size; i++ {
+		session, err := ort.NewDynamicAdvancedSession(modelPath, inputNames, outputNames, options)
+		if err != nil {
+			pool.destroy()
+			return nil, fmt.Errorf("failed to create ONNX session %d/%d: %v", i+1, size, err)
+		}
+		ps := &pooledSession{id: strconv.Itoa(i), session: session}
+		pool.sessions = append(pool.sessions, ps)
+		pool.free <- ps
+		sessionBusy.WithLabelValues(pool.model, ps.id).Set(0)
+	}
+	return pool, nil
+}
+
+// acquire blocks until a session is free
+func (p *sessionPool) acquire() *pooledSession {
+	ps := <-p.free
+	sessionBusy.WithLabelValues(p.model, ps.id).Set(1)
+	return ps
+}
+
+// release returns a session and records how long it was busy
+func (p *sessionPool) release(ps *pooledSession, busy time.Duration) {
+	sessionBusy.WithLabelValues(p.model, ps.id).Set(0)
+	sessionBusySeconds.WithLabelValues(p.model, ps.id).Add(busy.Seconds())
+	p.free <- ps
+}
+
+func (p *sessionPool) size() int {
+	return len(p.sessions)
+}
+
+// destroy frees every session; callers must make sure none are in use
+func (p *sessionPool) destroy() {
+	for _, ps := range p.sessions {
+		ps.session.Destroy()
+		sessionBusy.DeleteLabelValues(p.model, ps.id)
+		sessionBusySeconds.DeleteLabelValues(p.model, ps.id)
+	}
+	p.sessions = nil
+}
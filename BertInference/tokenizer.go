@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 	"unicode"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 func LoadVocab(path string) (map[string]int, error) {
@@ -18,7 +20,7 @@ func LoadVocab(path string) (map[string]int, error) {
 	scanner := bufio.NewScanner(file)
 	index := 0
 	for scanner.Scan() {
-		token := scanner.Text()
+		token := strings.TrimRight(scanner.Text(), "\r")
 		vocab[token] = index
 		index++
 	}
@@ -28,92 +30,307 @@ func LoadVocab(path string) (map[string]int, error) {
 	return vocab, nil
 }
 
-func encode_word(word string, vocab map[string]int) []string {
-	tokens := []string{}
+// Special tokens used by BERT vocabularies
+const (
+	unkToken  = "[UNK]"
+	clsToken  = "[CLS]"
+	sepToken  = "[SEP]"
+	maskToken = "[MASK]"
+)
 
-	for len(word) > 0 {
-		i := len(word)
-		found := false
+// specialTokens are never lowercased or split by the basic tokenizer
+var specialTokens = map[string]struct{}{
+	"[UNK]": {}, "[SEP]": {}, "[PAD]": {}, "[CLS]": {}, "[MASK]": {},
+}
 
-		for i > 0 {
-			sub := word[:i]
-			if _, exists := vocab[sub]; exists {
-				found = true
-				tokens = append(tokens, sub)
-				word = word[i:]
-				if len(word) > 0 {
-					word = "##" + word
-				}
-				break
-			}
-			i--
+// Tokenizer reproduces HuggingFace BertTokenizer: a BasicTokenizer (text
+// cleanup, CJK splitting, lowercasing, accent stripping, punctuation
+// splitting) followed by greedy longest-match-first WordPiece.
+type Tokenizer struct {
+	vocab                map[string]int
+	doLowerCase          bool
+	maxInputCharsPerWord int
+}
+
+// NewTokenizer returns an uncased tokenizer, matching FinBERT's
+// bert-base-uncased vocabulary
+func NewTokenizer(vocab map[string]int) *Tokenizer {
+	return &Tokenizer{
+		vocab:                vocab,
+		doLowerCase:          true,
+		maxInputCharsPerWord: 100,
+	}
+}
+
+// Tokenize splits text into WordPiece tokens. As in HuggingFace's
+// PreTrainedTokenizer.tokenize, special tokens are cut out first wherever
+// they appear, so "[CLS]foo" gives "[CLS]", "foo", and the text between
+// them is lowercased before the basic tokenizer sees it.
+func (t *Tokenizer) Tokenize(text string) []string {
+	var tokens []string
+	for _, segment := range splitOnSpecialTokens(text) {
+		if _, special := specialTokens[segment]; special {
+			tokens = append(tokens, segment)
+			continue
 		}
-		if !found {
-			tokens = append(tokens, "[UNK]")
-			break
+		if t.doLowerCase {
+			segment = strings.ToLower(segment)
+		}
+		for _, word := range t.basicTokenize(segment) {
+			if _, special := specialTokens[word]; special {
+				tokens = append(tokens, word)
+				continue
+			}
+			tokens = append(tokens, t.wordPiece(word)...)
 		}
 	}
 	return tokens
 }
 
-func TokenizeBert(text string, vocab map[string]int) []string {
-	tokens := []string{}
-	word := ""
+// splitOnSpecialTokens cuts text before and after every special token,
+// taking the leftmost match each time
+func splitOnSpecialTokens(text string) []string {
+	var out []string
+	for text != "" {
+		at, match := -1, ""
+		for token := range specialTokens {
+			if i := strings.Index(text, token); i >= 0 && (at < 0 || i < at) {
+				at, match = i, token
+			}
+		}
+		if at < 0 {
+			return append(out, text)
+		}
+		if at > 0 {
+			out = append(out, text[:at])
+		}
+		out = append(out, match)
+		text = text[at+len(match):]
+	}
+	return out
+}
 
-	for _, char := range text {
-		if unicode.IsLetter(char) || unicode.IsDigit(char) {
-			word += string(char)
-		} else {
-			if word != "" {
-				encoded_word := encode_word(strings.ToLower(word), vocab)
-				tokens = append(tokens, encoded_word...)
-				word = ""
+// occlusionToken returns [MASK], or [UNK] for vocabularies without it
+func (t *Tokenizer) occlusionToken() string {
+	if _, ok := t.vocab[maskToken]; ok {
+		return maskToken
+	}
+	return unkToken
+}
+
+// ConvertTokensToIDs maps tokens to vocabulary IDs, using [UNK] for
+// anything missing
+func (t *Tokenizer) ConvertTokensToIDs(tokens []string) []int64 {
+	ids := make([]int64, len(tokens))
+	for i, token := range tokens {
+		id, ok := t.vocab[token]
+		if !ok {
+			id = t.vocab[unkToken]
+		}
+		ids[i] = int64(id)
+	}
+	return ids
+}
+
+func (t *Tokenizer) basicTokenize(text string) []string {
+	text = cleanText(text)
+	text = padChineseChars(text)
+	// Matches BasicTokenizer: normalise to NFC so composed and decomposed
+	// input tokenise identically
+	text = norm.NFC.String(text)
+
+	var out []string
+	for _, token := range strings.Fields(text) {
+		if _, special := specialTokens[token]; special {
+			out = append(out, token)
+			continue
+		}
+		if t.doLowerCase {
+			token = stripAccents(strings.ToLower(token))
+		}
+		out = append(out, splitOnPunctuation(token)...)
+	}
+	return out
+}
+
+// wordPiece greedily matches the longest vocabulary prefix, continuing
+// with "##" pieces. A word that cannot be fully covered becomes one [UNK].
+func (t *Tokenizer) wordPiece(word string) []string {
+	chars := []rune(word)
+	if len(chars) > t.maxInputCharsPerWord {
+		return []string{unkToken}
+	}
+
+	var pieces []string
+	for start := 0; start < len(chars); {
+		end := len(chars)
+		found := ""
+		for start < end {
+			sub := string(chars[start:end])
+			if start > 0 {
+				sub = "##" + sub
 			}
-			if !unicode.IsSpace(char) {
-				tokens = append(tokens, string(char))
+			if _, ok := t.vocab[sub]; ok {
+				found = sub
+				break
 			}
+			end--
+		}
+		if found == "" {
+			return []string{unkToken}
 		}
+		pieces = append(pieces, found)
+		start = end
 	}
+	return pieces
+}
 
-	if word != "" {
-		tokens = append(tokens, word)
+// cleanText drops NUL, U+FFFD and control characters and maps every
+// whitespace character to a plain space
+func cleanText(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if r == 0 || r == 0xFFFD || isControl(r) {
+			continue
+		}
+		if isWhitespace(r) {
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(r)
+		}
 	}
+	return b.String()
+}
 
-	return tokens
+// padChineseChars surrounds CJK ideographs with spaces so each becomes its
+// own token (Japanese kana and Korean Hangul are left alone, as in BERT)
+func padChineseChars(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range text {
+		if isChineseChar(r) {
+			b.WriteByte(' ')
+			b.WriteRune(r)
+			b.WriteByte(' ')
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
 }
 
-func BertEncode(text string, vocab map[string]int, maxLen int) ([]int64, []int64, []int64) {
-	tokens := []string{"[CLS]"}
-	tokens = append(tokens, TokenizeBert(text, vocab)...)
-	tokens = append(tokens, "[SEP]")
+// stripAccents decomposes to NFD and drops combining marks (category Mn)
+func stripAccents(text string) string {
+	var b strings.Builder
+	b.Grow(len(text))
+	for _, r := range norm.NFD.String(text) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
 
-	inputIds := make([]int64, 0, len(tokens))
-	for _, token := range tokens {
-		id, exists := vocab[token]
-		if !exists {
-			id = vocab["[UNK]"]
+// splitOnPunctuation makes every punctuation character its own token
+func splitOnPunctuation(text string) []string {
+	var out []string
+	var current []rune
+	for _, r := range text {
+		if isPunctuation(r) {
+			if len(current) > 0 {
+				out = append(out, string(current))
+				current = current[:0]
+			}
+			out = append(out, string(r))
+			continue
 		}
-		inputIds = append(inputIds, int64(id))
+		current = append(current, r)
 	}
+	if len(current) > 0 {
+		out = append(out, string(current))
+	}
+	return out
+}
 
-	attentionMask := make([]int64, len(inputIds))
-	for i := range attentionMask {
-		attentionMask[i] = 1
+func isWhitespace(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return true
+	}
+	return unicode.Is(unicode.Zs, r)
+}
+
+// isControl matches every C* category, including unassigned code points
+// (Cn), which Go has no table for: anything outside the other categories
+func isControl(r rune) bool {
+	switch r {
+	case '\t', '\n', '\r':
+		return false
+	}
+	return !unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.S, unicode.Z)
+}
+
+// isPunctuation treats all non-alphanumeric ASCII as punctuation (so "$",
+// "^" and "`" split too), plus every Unicode P* category
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
 	}
+	return unicode.IsPunct(r)
+}
+
+// isChineseChar covers the CJK Unified Ideographs blocks
+func isChineseChar(r rune) bool {
+	return (r >= 0x4E00 && r <= 0x9FFF) ||
+		(r >= 0x3400 && r <= 0x4DBF) ||
+		(r >= 0x20000 && r <= 0x2A6DF) ||
+		(r >= 0x2A700 && r <= 0x2B73F) ||
+		(r >= 0x2B740 && r <= 0x2B81F) ||
+		(r >= 0x2B820 && r <= 0x2CEAF) ||
+		(r >= 0xF900 && r <= 0xFAFF) ||
+		(r >= 0x2F800 && r <= 0x2FA1F)
+}
+
+// TokenizeBert tokenizes text with an uncased BERT tokenizer
+func TokenizeBert(text string, vocab map[string]int) []string {
+	return NewTokenizer(vocab).Tokenize(text)
+}
 
-	tokenTypeIDs := make([]int64, len(inputIds))
+// BertEncode builds padded input_ids, attention_mask and token_type_ids for
+// a single sequence: [CLS] tokens [SEP], truncated to maxLen so that [SEP]
+// is always kept.
+func BertEncode(text string, vocab map[string]int, maxLen int) ([]int64, []int64, []int64) {
+	tok := NewTokenizer(vocab)
+	return tok.Encode(tok.Tokenize(text), maxLen)
+}
 
-	for len(inputIds) < maxLen {
-		inputIds = append(inputIds, 0)
-		attentionMask = append(attentionMask, 0)
-		tokenTypeIDs = append(tokenTypeIDs, 0)
+// Encode wraps already-tokenized text in [CLS] ... [SEP], truncating so that
+// [SEP] is always kept, and pads it to maxLen
+func (t *Tokenizer) Encode(tokens []string, maxLen int) ([]int64, []int64, []int64) {
+	if len(tokens) > maxLen-2 {
+		tokens = tokens[:maxLen-2]
 	}
 
-	// Truncate if too long
-	if len(inputIds) > maxLen {
-		inputIds = inputIds[:maxLen]
-		attentionMask = attentionMask[:maxLen]
-		tokenTypeIDs = tokenTypeIDs[:maxLen]
+	seq := make([]string, 0, len(tokens)+2)
+	seq = append(seq, clsToken)
+	seq = append(seq, tokens...)
+	seq = append(seq, sepToken)
+
+	return padSequence(t.ConvertTokensToIDs(seq), maxLen)
+}
+
+// padSequence pads ids with zeros up to maxLen and builds the matching
+// attention mask and (single-segment) token type IDs
+func padSequence(ids []int64, maxLen int) ([]int64, []int64, []int64) {
+	inputIds := make([]int64, maxLen)
+	attentionMask := make([]int64, maxLen)
+	tokenTypeIDs := make([]int64, maxLen)
+
+	copy(inputIds, ids)
+	for i := 0; i < len(ids) && i < maxLen; i++ {
+		attentionMask[i] = 1
 	}
 
 	return inputIds, attentionMask, tokenTypeIDs
@@ -0,0 +1,63 @@
+package BertInference
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"testing"
+)
+
+// goldenCase is one line of testdata/tokenizer_golden.jsonl, written by
+// sentAnalysis/tokenizer_golden.py from HuggingFace BertTokenizer
+type goldenCase struct {
+	Text   string   `json:"text"`
+	Tokens []string `json:"tokens"`
+	IDs    []int64  `json:"ids"`
+}
+
+func TestTokenizerGolden(t *testing.T) {
+	vocab, err := LoadVocab("testdata/vocab.txt")
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("testdata/vocab.txt not present")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	tok := NewTokenizer(vocab)
+
+	file, err := os.Open("testdata/tokenizer_golden.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	cases := 0
+	for scanner.Scan() {
+		var tc goldenCase
+		if err := json.Unmarshal(scanner.Bytes(), &tc); err != nil {
+			t.Fatalf("line %d: %v", cases+1, err)
+		}
+		cases++
+
+		tokens := tok.Tokenize(tc.Text)
+		if !slices.Equal(tokens, tc.Tokens) {
+			t.Errorf("Tokenize(%q)\n got: %q\nwant: %q", tc.Text, tokens, tc.Tokens)
+			continue
+		}
+		ids := tok.ConvertTokensToIDs(append(append([]string{clsToken}, tokens...), sepToken))
+		if !slices.Equal(ids, tc.IDs) {
+			t.Errorf("ids for %q\n got: %v\nwant: %v", tc.Text, ids, tc.IDs)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if cases == 0 {
+		t.Fatal("no golden cases")
+	}
+}
@@ -0,0 +1,205 @@
+// Command evalsentiment runs a labelled dataset of financial sentences
+// through a registered sentiment model and reports accuracy, per-class
+// precision/recall/F1, expected calibration error and latency percentiles.
+// With -baseline it exits non-zero when quality regresses against a stored
+// report; with -fit it fits a calibration file for the model from the raw
+// logits. Without -tags=onnx it evaluates the lexicon fallback.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/MadebyDaris/dogonomics/internal/evaluation"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run returns the exit code: 0 on success, 1 on regression, 2 on errors
+func run() int {
+	dataPath := flag.String("data", "cmd/evalsentiment/testdata/sample.jsonl", "labelled dataset (.csv or .jsonl)")
+	manifestPath := flag.String("models", "sentAnalysis/models.json", "path to the model manifest")
+	modelName := flag.String("model", "", "model to evaluate (default: the manifest default)")
+	concurrency := flag.Int("concurrency", 4, "sentences scored in parallel")
+	baselinePath := flag.String("baseline", "", "report to compare against; exit 1 on regression")
+	tolerance := flag.Float64("tolerance", 0.01, "allowed drop in accuracy/F1 (or rise in ECE) before failing")
+	outPath := flag.String("out", "", "write the report as JSON, e.g. to store a new baseline")
+	fitMethod := flag.String("fit", "", "fit a calibration: temperature or isotonic")
+	fitOut := flag.String("fit-out", "calibration.json", "where -fit writes the calibration")
+	flag.Parse()
+
+	examples, err := evaluation.LoadDataset(*dataPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load dataset: %v\n", err)
+		return 2
+	}
+
+	if err := BertInference.InitializeBERT(*manifestPath); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to initialize BERT: %v\n", err)
+		return 2
+	}
+	defer BertInference.CleanupBERT()
+
+	predictions, modelID, err := predict(*modelName, examples, max(*concurrency, 1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "inference failed: %v\n", err)
+		return 2
+	}
+
+	report, err := evaluation.Evaluate(examples, predictions)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "evaluation failed: %v\n", err)
+		return 2
+	}
+	report.Model = modelID
+	report.Dataset = *dataPath
+	printReport(report)
+
+	if *outPath != "" {
+		if err := evaluation.SaveReport(*outPath, report); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write report: %v\n", err)
+			return 2
+		}
+	}
+
+	if *fitMethod != "" {
+		calibration, err := evaluation.FitCalibration(*fitMethod, examples, predictions)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to fit calibration: %v\n", err)
+			return 2
+		}
+		calibration.Model = modelID
+		calibration.Dataset = *dataPath
+		calibration.FittedAt = time.Now().UTC()
+		if err := writeJSON(*fitOut, calibration); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write calibration: %v\n", err)
+			return 2
+		}
+		fmt.Printf("\nfitted %s calibration: ECE %.4f -> %.4f on the fitting set, written to %s\n",
+			calibration.Method, calibration.ECEBefore, calibration.ECEAfter, *fitOut)
+	}
+
+	if *baselinePath == "" {
+		return 0
+	}
+	baseline, err := evaluation.LoadReport(*baselinePath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load baseline: %v\n", err)
+		return 2
+	}
+	regressions := evaluation.Compare(report, baseline, *tolerance)
+	if len(regressions) == 0 {
+		fmt.Printf("\nno regressions against %s (%s)\n", *baselinePath, baseline.Model)
+		return 0
+	}
+	fmt.Printf("\nREGRESSED against %s (%s), tolerance %.4f:\n", *baselinePath, baseline.Model, *tolerance)
+	for _, r := range regressions {
+		fmt.Printf("  %s\n", r)
+	}
+	return 1
+}
+
+// predict scores every example one request at a time from several workers,
+// so latency reflects a single request going through the batcher
+func predict(model string, examples []evaluation.Example, workers int) ([]evaluation.Prediction, string, error) {
+	predictions := make([]evaluation.Prediction, len(examples))
+	ids := make([]string, len(examples))
+	errs := make([]error, len(examples))
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				start := time.Now()
+				results, err := BertInference.RunModelDocuments(model, []BertInference.Document{{Body: examples[i].Text}})
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				predictions[i] = evaluation.Prediction{
+					Label:      results[0].Label,
+					Confidence: results[0].Confidence,
+					Logits:     results[0].Logits,
+					Latency:    time.Since(start),
+				}
+				ids[i] = results[0].Model
+			}
+		}()
+	}
+	for i := range examples {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, "", fmt.Errorf("example %d: %v", i+1, err)
+		}
+	}
+	return predictions, ids[0], nil
+}
+
+func printReport(r *evaluation.Report) {
+	fmt.Printf("model     %s\n", r.Model)
+	fmt.Printf("dataset   %s (%d examples)\n", r.Dataset, r.Examples)
+	fmt.Printf("accuracy  %.4f\n", r.Accuracy)
+	fmt.Printf("macro F1  %.4f\n", r.MacroF1)
+	fmt.Printf("ECE       %.4f\n\n", r.ECE)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "class\tprecision\trecall\tf1\tsupport\t")
+	for _, class := range evaluation.Classes {
+		m := r.Classes[class]
+		fmt.Fprintf(w, "%s\t%.4f\t%.4f\t%.4f\t%d\t\n", class, m.Precision, m.Recall, m.F1, m.Support)
+	}
+	w.Flush()
+
+	fmt.Println("\nconfusion (rows: true, columns: predicted)")
+	fmt.Fprint(w, "\t")
+	for _, class := range evaluation.Classes {
+		fmt.Fprintf(w, "%s\t", class)
+	}
+	fmt.Fprintln(w)
+	for _, truth := range evaluation.Classes {
+		fmt.Fprintf(w, "%s\t", truth)
+		for _, pred := range evaluation.Classes {
+			fmt.Fprintf(w, "%d\t", r.Confusion[truth][pred])
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	fmt.Println("\ncalibration")
+	fmt.Fprintln(w, "confidence\tcount\tmean conf\taccuracy\t")
+	for _, b := range r.Calibration {
+		if b.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%.1f-%.1f\t%d\t%.4f\t%.4f\t\n", b.Lower, b.Upper, b.Count, b.MeanConfidence, b.Accuracy)
+	}
+	w.Flush()
+
+	l := r.Latency
+	fmt.Printf("\nlatency ms  p50 %.1f  p90 %.1f  p95 %.1f  p99 %.1f  max %.1f  mean %.1f\n",
+		l.P50, l.P90, l.P95, l.P99, l.Max, l.Mean)
+}
+
+func writeJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
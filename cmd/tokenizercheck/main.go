@@ -0,0 +1,77 @@
+// Command tokenizercheck compares the Go WordPiece tokenizer against a golden
+// file produced by HuggingFace BertTokenizer (sentAnalysis/tokenizer_golden.py)
+// and exits non-zero on any mismatch. The defaults are the committed test
+// vocab and golden file that go test also checks; pass -vocab and -golden
+// to compare against a real vocabulary.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+)
+
+type goldenCase struct {
+	Text   string   `json:"text"`
+	Tokens []string `json:"tokens"`
+	IDs    []int64  `json:"ids"`
+}
+
+func main() {
+	vocabPath := flag.String("vocab", "BertInference/testdata/vocab.txt", "path to the vocab.txt the golden file was made with")
+	goldenPath := flag.String("golden", "BertInference/testdata/tokenizer_golden.jsonl", "path to the golden JSONL file")
+	flag.Parse()
+
+	vocab, err := BertInference.LoadVocab(*vocabPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to load vocab: %v\n", err)
+		os.Exit(2)
+	}
+	tok := BertInference.NewTokenizer(vocab)
+
+	file, err := os.Open(*goldenPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open golden file: %v\n", err)
+		os.Exit(2)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	total, failed := 0, 0
+	for scanner.Scan() {
+		var tc goldenCase
+		if err := json.Unmarshal(scanner.Bytes(), &tc); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", total+1, err)
+			os.Exit(2)
+		}
+		total++
+
+		tokens := tok.Tokenize(tc.Text)
+		ids := tok.ConvertTokensToIDs(append(append([]string{"[CLS]"}, tokens...), "[SEP]"))
+		if slices.Equal(tokens, tc.Tokens) && slices.Equal(ids, tc.IDs) {
+			continue
+		}
+
+		failed++
+		fmt.Printf("MISMATCH %q\n  want tokens: %q\n  got tokens:  %q\n", tc.Text, tc.Tokens, tokens)
+		if !slices.Equal(ids, tc.IDs) {
+			fmt.Printf("  want ids: %v\n  got ids:  %v\n", tc.IDs, ids)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read golden file: %v\n", err)
+		os.Exit(2)
+	}
+
+	fmt.Printf("%d/%d cases match\n", total-failed, total)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -2,10 +2,14 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/MadebyDaris/dogonomics/BertInference"
@@ -13,9 +17,16 @@ import (
 	"github.com/MadebyDaris/dogonomics/internal/DogonomicsFetching"
 	"github.com/MadebyDaris/dogonomics/internal/NewsClient"
 	"github.com/MadebyDaris/dogonomics/internal/PolygonClient"
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
 	"github.com/MadebyDaris/dogonomics/internal/TreasuryClient"
+	"github.com/MadebyDaris/dogonomics/internal/bulk"
+	"github.com/MadebyDaris/dogonomics/internal/cache"
 	"github.com/MadebyDaris/dogonomics/internal/database"
-	"github.com/MadebyDaris/dogonomics/internal/workerpool"
+	"github.com/MadebyDaris/dogonomics/internal/jobs"
+	"github.com/MadebyDaris/dogonomics/internal/scheduler"
+	"github.com/MadebyDaris/dogonomics/internal/sentimentcache"
+	"github.com/MadebyDaris/dogonomics/internal/shadow"
+	"github.com/MadebyDaris/dogonomics/middleware"
 	"github.com/MadebyDaris/dogonomics/sentAnalysis"
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +37,7 @@ var (
 	treasuryClient           *TreasuryClient.Client
 	commoditiesClient        *CommoditiesClient.Client
 	newsClient               *NewsClient.NewsClient
+	jobScheduler             *scheduler.Scheduler
 )
 
 // ErrorResponse represents a standard error payload
@@ -35,9 +47,10 @@ type ErrorResponse struct {
 
 // NewsSentimentBERTResponse is the response schema for /finnewsBert/{symbol}
 type NewsSentimentBERTResponse struct {
-	Symbol          string                               `json:"symbol"`
-	AggregateResult *sentAnalysis.StockSentimentAnalysis `json:"aggregate_result"`
-	NewsItems       []sentAnalysis.NewsItem              `json:"news_items"`
+	Symbol          string                                          `json:"symbol"`
+	AggregateResult *sentAnalysis.StockSentimentAnalysis            `json:"aggregate_result"`
+	TickerSentiment map[string]*sentAnalysis.StockSentimentAnalysis `json:"ticker_sentiment"`
+	NewsItems       []sentAnalysis.NewsItem                         `json:"news_items"`
 }
 
 // SentimentOnlyResponse is the response schema for /sentiment/{symbol}
@@ -142,109 +155,161 @@ func GetNews(c *gin.Context) {
 // @Summary      Get aggregate sentiment only
 // @Description  Fetches recent news and returns only the aggregate sentiment values
 // @Tags         sentiment
-// @Param        symbol   path   string  true  "Ticker symbol (e.g., AAPL)"
+// @Param        symbol   path   string  true   "Ticker symbol (e.g., AAPL)"
+// @Param        async    query  bool    false  "Run as a background job and respond 202 with its links"
 // @Produce      json
 // @Success      200  {object}  SentimentOnlyResponse
+// @Success      202  {object}  interface{}
 // @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
 // @Router       /sentiment/{symbol} [get]
 func GetSentimentOnly(c *gin.Context) {
-	ctx := c.Request.Context()
 	symbol := c.Param("symbol")
 
-	newsItems, err := sentAnalysis.FetchAndAnalyzeNews(ctx, symbol)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("failed to fetch/analyze news: %v", err),
-		})
-		return
-	}
+	runAnalysis(c, "sentiment", gin.H{"symbol": symbol}, func(ctx context.Context) (any, error) {
+		newsItems, err := sentAnalysis.FetchAndAnalyzeNews(ctx, newsClient, symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch/analyze news: %v", err)
+		}
 
-	aggregate := sentAnalysis.FetchStockSentiment(ctx, newsItems)
+		aggregate := sentAnalysis.Aggregate(newsItems)
 
-	// Persist aggregate sentiment to database asynchronously
-	go func() {
-		dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+		// Persist aggregate sentiment to database asynchronously
+		go func() {
+			dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
 
-		if err := database.SaveAggregatedSentiment(dbCtx, symbol, aggregate); err != nil {
-			log.Printf("Failed to save aggregate sentiment for %s: %v", symbol, err)
-		}
-	}()
+			if err := database.SaveAggregatedSentiment(dbCtx, symbol, aggregate); err != nil {
+				log.Printf("Failed to save aggregate sentiment for %s: %v", symbol, err)
+			}
+		}()
 
-	c.JSON(http.StatusOK, gin.H{
-		"symbol":    symbol,
-		"sentiment": aggregate,
+		return gin.H{
+			"symbol":    symbol,
+			"sentiment": aggregate,
+		}, nil
 	})
 }
 
 // GetNewsSentimentBERT godoc
 // @Summary      Get news with BERT sentiment
-// @Description  Fetches news for a symbol and returns items with BERT sentiment and aggregate
+// @Description  Fetches news for a symbol and returns items with BERT sentiment, per-aspect sentiment and aggregate. The aspect filters narrow the returned items and aggregates; everything fetched is still stored.
 // @Tags         sentiment
-// @Param        symbol   path   string  true  "Ticker symbol (e.g., AAPL)"
+// @Param        symbol        path   string  true   "Ticker symbol (e.g., AAPL)"
+// @Param        chunks        query  bool    false  "Include per-chunk scores for each article"
+// @Param        aspect        query  string  false  "Only articles about these aspects, comma-separated (earnings, guidance, legal, mergers, management, product, macro)"
+// @Param        aspect_label  query  string  false  "Only articles where a selected aspect has this sentiment (positive, neutral, negative)"
+// @Param        async         query  bool    false  "Run as a background job and respond 202 with its links"
 // @Produce      json
 // @Success      200  {object}  NewsSentimentBERTResponse
+// @Success      202  {object}  interface{}
+// @Failure      400  {object}  ErrorResponse
 // @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
 // @Router       /finnewsBert/{symbol} [get]
 func GetNewsSentimentBERT(c *gin.Context) {
-	ctx := c.Request.Context()
 	symbol := c.Param("symbol")
 
-	newsItems, err := sentAnalysis.FetchAndAnalyzeNews(ctx, symbol)
+	aspects, err := sentAnalysis.ParseAspects(c.Query("aspect"))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("failed to fetch/analyze news: %v", err),
-		})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	aspectLabel := strings.ToLower(c.Query("aspect_label"))
+	switch aspectLabel {
+	case "", BertInference.LabelPositive, BertInference.LabelNeutral, BertInference.LabelNegative:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "aspect_label must be positive, neutral or negative"})
 		return
 	}
 
-	aggregate := sentAnalysis.FetchStockSentiment(ctx, newsItems)
+	includeChunks := wantChunks(c)
+	params := gin.H{"symbol": symbol, "aspect": aspects, "aspect_label": aspectLabel, "chunks": includeChunks}
 
-	// Persist news items and sentiment to database asynchronously
-	go func() {
-		dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
+	runAnalysis(c, "news_sentiment", params, func(ctx context.Context) (any, error) {
+		newsItems, err := sentAnalysis.FetchAndAnalyzeNews(ctx, newsClient, symbol)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch/analyze news: %v", err)
+		}
 
-		// Save each news item
-		for _, newsItem := range newsItems {
-			newsID, err := database.SaveNewsWithSentiment(dbCtx, symbol, &newsItem)
-			if err != nil {
-				log.Printf("Failed to save news item for %s: %v", symbol, err)
-				continue
-			}
+		aggregate := sentAnalysis.Aggregate(newsItems)
+		shadow.Submit(shadow.FromNewsItems(symbol, newsItems))
 
-			// Save sentiment analysis for this news item
-			if err := database.SaveNewsSentiment(dbCtx, newsID, &newsItem); err != nil {
-				log.Printf("Failed to save sentiment analysis for news %s: %v", newsID, err)
+		if !includeChunks {
+			for i := range newsItems {
+				newsItems[i].Chunks = nil
 			}
 		}
 
-		// Save aggregate sentiment
-		if err := database.SaveAggregatedSentiment(dbCtx, symbol, aggregate); err != nil {
-			log.Printf("Failed to save aggregate sentiment for %s: %v", symbol, err)
+		// Persist news items and sentiment to database asynchronously
+		go func() {
+			dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// Save each news item
+			for _, newsItem := range newsItems {
+				newsID, err := database.SaveNewsWithSentiment(dbCtx, symbol, &newsItem)
+				if err != nil {
+					log.Printf("Failed to save news item for %s: %v", symbol, err)
+					continue
+				}
+
+				// Save sentiment once per ticker the article mentions
+				for _, ticker := range SymbolLinker.WithSymbol(symbol, newsItem.Symbols) {
+					if err := database.SaveNewsSentiment(dbCtx, newsID, ticker, &newsItem); err != nil {
+						log.Printf("Failed to save sentiment analysis for news %s (%s): %v", newsID, ticker, err)
+					}
+				}
+			}
+
+			// Save aggregate sentiment
+			if err := database.SaveAggregatedSentiment(dbCtx, symbol, aggregate); err != nil {
+				log.Printf("Failed to save aggregate sentiment for %s: %v", symbol, err)
+			}
+		}()
+
+		// Filter only the response; all fetched items are persisted above
+		filtered := sentAnalysis.FilterByAspect(newsItems, aspects, aspectLabel)
+		result := aggregate
+		if len(filtered) != len(newsItems) {
+			result = sentAnalysis.Aggregate(filtered)
+			result.Symbol = aggregate.Symbol
 		}
-	}()
 
-	c.JSON(http.StatusOK, gin.H{
-		"symbol":           symbol,
-		"aggregate_result": aggregate,
-		"news_items":       newsItems,
+		return gin.H{
+			"symbol":           symbol,
+			"aggregate_result": result,
+			"ticker_sentiment": sentAnalysis.AggregateByTicker(filtered),
+			"news_items":       filtered,
+		}, nil
 	})
 }
 
 // InferenceRequest represents the request body for FinBERT inference
 type InferenceRequest struct {
-	Text string `json:"text" binding:"required"`
+	Text   string `json:"text" binding:"required"`
+	Title  string `json:"title"`
+	Chunks bool   `json:"chunks"`
+	// Model names a registry model; empty uses the default
+	Model string `json:"model"`
+	// Explain adds token attributions for text to the response
+	Explain bool `json:"explain"`
+}
+
+// InferenceResponse is the response schema for /finbert/inference
+type InferenceResponse struct {
+	*BertInference.DocumentSentiment
+	Explanation *BertInference.Explanation `json:"explanation,omitempty"`
 }
 
 // RunFinBertInference godoc
 // @Summary      Run FinBERT inference on custom text
-// @Description  Analyzes sentiment of provided text using DoggoFinBERT model
+// @Description  Analyzes sentiment of provided text with a registry model (the default unless "model" is set). Text longer than one model window is split into overlapping chunks; an optional title is scored separately and weighted as a headline. With "explain" the response includes each token's contribution to each class, found by occluding the tokens of the text one at a time.
 // @Tags         sentiment
 // @Accept       json
 // @Produce      json
 // @Param        request  body      InferenceRequest  true  "Text to analyze"
-// @Success      200      {object}  BertInference.BERTSentiment
+// @Success      200      {object}  InferenceResponse
 // @Failure      400      {object}  ErrorResponse
 // @Failure      500      {object}  ErrorResponse
 // @Router       /finbert/inference [post]
@@ -265,16 +330,343 @@ func RunFinBertInference(c *gin.Context) {
 		return
 	}
 
-	modelPath := "./sentAnalysis/DoggoFinBERT.onnx"
-	sentiment, err := BertInference.RunBERTInference(req.Text, modelPath)
+	results, err := sentimentcache.RunDocuments(c.Request.Context(), req.Model, []BertInference.Document{sentAnalysis.NewsDocument(req.Title, req.Text)})
+	if errors.Is(err, BertInference.ErrUnknownModel) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  err.Error(),
+			"models": BertInference.Models(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to run BERT inference: %v", err),
 		})
 		return
 	}
+	sentiment := results[0]
+	if !req.Chunks {
+		sentiment.Chunks = nil
+	}
+	if !req.Explain {
+		c.JSON(http.StatusOK, sentiment)
+		return
+	}
+
+	explanation, err := BertInference.ExplainModel(req.Model, req.Text)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to explain BERT inference: %v", err),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, InferenceResponse{DocumentSentiment: sentiment, Explanation: explanation})
+}
+
+// jobLinks are the follow-up URLs for an asynchronous job
+func jobLinks(status jobs.Status) gin.H {
+	links := gin.H{
+		"status": "/jobs/" + status.ID,
+		"events": "/jobs/" + status.ID + "/events",
+		"result": "/jobs/" + status.ID + "/result",
+	}
+	if status.Kind == bulk.Kind {
+		links["download"] = "/finbert/batch/" + status.ID + "/results"
+	}
+	return links
+}
+
+// wantAsync reports whether the request asked to run as a background job
+func wantAsync(c *gin.Context) bool {
+	async, _ := strconv.ParseBool(c.Query("async"))
+	return async
+}
+
+// runAnalysis responds with the result of analyse, or with ?async=true
+// submits it as a background job and responds 202 with the job's links
+func runAnalysis(c *gin.Context, kind string, params any, analyse func(ctx context.Context) (any, error)) {
+	if wantAsync(c) {
+		status, err := jobs.Submit(kind, params, func(ctx context.Context, _ *jobs.Progress) (any, error) {
+			return analyse(ctx)
+		})
+		acceptJob(c, status, err)
+		return
+	}
+
+	result, err := analyse(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// acceptJob responds to a job submission
+func acceptJob(c *gin.Context, status jobs.Status, err error) {
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Location", "/jobs/"+status.ID)
+	c.JSON(http.StatusAccepted, gin.H{
+		"job":   status,
+		"links": jobLinks(status),
+	})
+}
+
+// GetJob godoc
+// @Summary      Get an asynchronous job
+// @Description  Returns the state and progress of a job submitted with ?async=true or to /finbert/batch. Finished jobs are kept for JOB_RETENTION.
+// @Tags         jobs
+// @Param        id   path  string  true  "Job ID"
+// @Produce      json
+// @Success      200  {object}  interface{}
+// @Failure      404  {object}  ErrorResponse
+// @Router       /jobs/{id} [get]
+func GetJob(c *gin.Context) {
+	status, err := jobs.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, jobs.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"job":   status,
+		"links": jobLinks(status),
+	})
+}
+
+// GetJobResult godoc
+// @Summary      Get an asynchronous job's result
+// @Description  Returns the result of a finished job: the same body the endpoint returns synchronously. Failed and cancelled jobs return their partial result, if any, with the error.
+// @Tags         jobs
+// @Param        id   path  string  true  "Job ID"
+// @Produce      json
+// @Success      200  {object}  interface{}
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /jobs/{id}/result [get]
+func GetJobResult(c *gin.Context) {
+	status, result, err := jobs.Result(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, jobs.ErrNotFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job": status})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case status.State != jobs.StateCompleted:
+		response := gin.H{"job": status, "error": status.Error}
+		if len(result) > 0 {
+			response["partial_result"] = result
+		}
+		c.JSON(http.StatusOK, response)
+	default:
+		c.Data(http.StatusOK, "application/json; charset=utf-8", result)
+	}
+}
+
+// StreamJob godoc
+// @Summary      Stream job progress
+// @Description  Server-sent events: a "progress" event with the job status on every change (and at least every 15 seconds), then one "done" event when the job finishes
+// @Tags         jobs
+// @Param        id   path  string  true  "Job ID"
+// @Produce      text/event-stream
+// @Success      200  {object}  jobs.Status
+// @Failure      404  {object}  ErrorResponse
+// @Router       /jobs/{id}/events [get]
+func StreamJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if _, err := jobs.Get(ctx, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	for {
+		// Watch before reading so no change is missed in between
+		changed := jobs.Watch(id)
+		status, err := jobs.Get(ctx, id)
+		if err != nil {
+			c.SSEvent("error", gin.H{"error": err.Error()})
+			c.Writer.Flush()
+			return
+		}
+		if status.Done() {
+			c.SSEvent("done", status)
+			c.Writer.Flush()
+			return
+		}
+		c.SSEvent("progress", status)
+		c.Writer.Flush()
+
+		// Jobs this process is not running are polled
+		var poll <-chan time.Time
+		if changed == nil {
+			poll = time.After(2 * time.Second)
+		}
+		select {
+		case <-changed:
+		case <-poll:
+		case <-keepalive.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// CancelJob godoc
+// @Summary      Cancel an asynchronous job
+// @Description  Stops a queued or running job. Work already done is kept as a partial result. Finished jobs are left as they are.
+// @Tags         jobs
+// @Param        id   path  string  true  "Job ID"
+// @Produce      json
+// @Success      202  {object}  interface{}
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /jobs/{id} [delete]
+func CancelJob(c *gin.Context) {
+	status, err := jobs.Cancel(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, jobs.ErrNotLocal):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job": status})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{
+			"job":   status,
+			"links": jobLinks(status),
+		})
+	}
+}
+
+// SubmitBatchInference godoc
+// @Summary      Score a list of texts in the background
+// @Description  Accepts a JSON array (strings or {id, title, text} objects), NDJSON or a CSV with a text column, either as the request body or as a multipart "file" upload, and scores it as a background job. The format comes from ?format, the file extension or the Content-Type. Follow the job at /jobs/{id}, then download the results.
+// @Tags         sentiment
+// @Accept       json
+// @Accept       mpfd
+// @Produce      json
+// @Param        format  query     string  false  "Upload format: json, ndjson or csv"
+// @Param        model   query     string  false  "Registry model name (default model when empty)"
+// @Success      202     {object}  interface{}
+// @Failure      400     {object}  ErrorResponse
+// @Failure      413     {object}  ErrorResponse
+// @Failure      503     {object}  ErrorResponse
+// @Router       /finbert/batch [post]
+func SubmitBatchInference(c *gin.Context) {
+	maxItems, maxBytes := bulk.Limits()
+
+	model := c.Query("model")
+	if model != "" && !slices.ContainsFunc(BertInference.Models(), func(m BertInference.ModelInfo) bool { return m.Name == model }) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  fmt.Sprintf("%v: %q", BertInference.ErrUnknownModel, model),
+			"models": BertInference.Models(),
+		})
+		return
+	}
 
-	c.JSON(http.StatusOK, sentiment)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+	var body io.Reader = c.Request.Body
+	format := bulk.DetectFormat(c.Query("format"), c.ContentType(), "")
+	if c.ContentType() == "multipart/form-data" {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			c.JSON(status, gin.H{"error": fmt.Sprintf("invalid upload: %v", err)})
+			return
+		}
+		defer file.Close()
+		body = file
+		format = bulk.DetectFormat(c.Query("format"), header.Header.Get("Content-Type"), header.Filename)
+	}
+	if format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown upload format: set ?format=json|ndjson|csv or a matching Content-Type"})
+		return
+	}
+
+	items, err := bulk.Parse(body, format, maxItems)
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, bulk.ErrTooManyItems) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	status, err := bulk.Submit(items, model, format)
+	acceptJob(c, status, err)
+}
+
+// GetBatchInferenceResults godoc
+// @Summary      Download bulk job results
+// @Description  Returns the results of a finished bulk job in upload order as NDJSON (default) or CSV. Cancelled jobs return the texts scored before cancellation.
+// @Tags         sentiment
+// @Param        id      path   string  true   "Job ID"
+// @Param        format  query  string  false  "ndjson (default) or csv"
+// @Produce      application/x-ndjson
+// @Produce      text/csv
+// @Success      200  {string}  string
+// @Failure      400  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Router       /finbert/batch/{id}/results [get]
+func GetBatchInferenceResults(c *gin.Context) {
+	format := c.DefaultQuery("format", bulk.FormatNDJSON)
+	if format != bulk.FormatNDJSON && format != bulk.FormatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or csv"})
+		return
+	}
+
+	status, data, err := jobs.Result(c.Request.Context(), c.Param("id"))
+	if err == nil && status.Kind != bulk.Kind {
+		err = jobs.ErrNotFound
+	}
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "bulk job not found"})
+		return
+	case errors.Is(err, jobs.ErrNotFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "job": status})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := bulk.DecodeResults(data)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to decode results: %v", err)})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="sentiment-%s.%s"`, status.ID, format))
+	if format == bulk.FormatCSV {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		err = bulk.WriteCSV(c.Writer, results)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		err = bulk.WriteNDJSON(c.Writer, results)
+	}
+	if err != nil {
+		log.Printf("Failed to write results of bulk job %s: %v", status.ID, err)
+	}
 }
 
 // GetStockDetail godoc
@@ -312,6 +704,19 @@ func GetCompanyProfile(c *gin.Context) {
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"GetCompanyProfile error": err.Error()})
 	}
+	if err == nil && StockDetail.Name != "" {
+		// Grow the ticker dictionary used to link news to symbols
+		SymbolLinker.Default.Add(SymbolLinker.Company{Symbol: symbol, Name: StockDetail.Name})
+
+		go func() {
+			dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := database.SaveCompanyProfile(dbCtx, SymbolLinker.NormalizeSymbol(symbol), StockDetail); err != nil {
+				log.Printf("Failed to save company profile for %s: %v", symbol, err)
+			}
+		}()
+	}
 	c.JSON(http.StatusOK, StockDetail)
 }
 
@@ -597,6 +1002,7 @@ func GetGeneralFinanceNews(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	archiveArticles("", articles)
 
 	c.JSON(http.StatusOK, gin.H{
 		"category": category,
@@ -633,6 +1039,7 @@ func GetNewsBySymbolMultiSource(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	archiveProviderRated(symbol, articles)
 
 	c.JSON(http.StatusOK, gin.H{
 		"symbol":   symbol,
@@ -642,11 +1049,16 @@ func GetNewsBySymbolMultiSource(c *gin.Context) {
 }
 
 // SearchNews godoc
-// @Summary      Search news by keyword
-// @Description  Returns news articles matching the search keyword
+// @Summary      Search the news archive
+// @Description  Full-text search over every archived article, ranked by relevance. Supports "quoted phrases", OR and -exclusions. Falls back to a live keyword search when the database is unavailable, or when a plain keyword search without filters finds nothing in the archive.
 // @Tags         news
-// @Param        q      query  string  true   "Search keyword"
-// @Param        limit  query  int     false  "Number of articles to return (default: 10)"
+// @Param        q       query  string  true   "Search query (websearch syntax)"
+// @Param        symbol  query  string  false  "Restrict to a stock symbol"
+// @Param        source  query  string  false  "Restrict to a source (substring match, e.g. Reuters)"
+// @Param        from    query  string  false  "Published on or after (YYYY-MM-DD)"
+// @Param        to      query  string  false  "Published before (YYYY-MM-DD)"
+// @Param        limit   query  int     false  "Number of articles to return (default: 10, max: 50)"
+// @Param        cursor  query  string  false  "Cursor from the previous page's next_cursor"
 // @Produce      json
 // @Success      200  {object}  interface{}
 // @Failure      400  {object}  ErrorResponse
@@ -668,6 +1080,53 @@ func SearchNews(c *gin.Context) {
 		limit = 50
 	}
 
+	from, err := parseDateQuery(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	to, err := parseDateQuery(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	params := database.NewsSearchParams{
+		Query:  keyword,
+		Symbol: c.Query("symbol"),
+		Source: c.Query("source"),
+		From:   from,
+		To:     to,
+		Limit:  limit,
+		Cursor: c.Query("cursor"),
+	}
+
+	if database.DB != nil {
+		page, err := database.SearchNewsArchive(c.Request.Context(), params)
+		if errors.Is(err, database.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		// An empty first page of a plain keyword search means the archive has
+		// nothing yet; try the live sources. They cannot apply filters or
+		// websearch syntax, so an empty filtered search is the answer.
+		if len(page.Articles) > 0 || params.Cursor != "" || !liveSearchable(params) {
+			c.JSON(http.StatusOK, gin.H{
+				"keyword":     keyword,
+				"source":      "archive",
+				"count":       len(page.Articles),
+				"articles":    page.Articles,
+				"next_cursor": page.NextCursor,
+			})
+			return
+		}
+	}
+
 	articles, err := newsClient.GetNewsByKeyword(c.Request.Context(), keyword, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -676,23 +1135,116 @@ func SearchNews(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"keyword":  keyword,
+		"source":   "live",
 		"count":    len(articles),
 		"articles": articles,
 	})
 }
 
+// liveSearchable reports whether a live keyword search answers params as
+// asked: no filters and no quoted phrases, OR or -exclusions
+func liveSearchable(params database.NewsSearchParams) bool {
+	if params.Symbol != "" || params.Source != "" || params.From != nil || params.To != nil {
+		return false
+	}
+	for _, word := range strings.Fields(params.Query) {
+		if word == "OR" || strings.HasPrefix(word, "-") || strings.Contains(word, `"`) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseDateQuery parses an optional YYYY-MM-DD query parameter
+func parseDateQuery(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid '%s' date, use YYYY-MM-DD", name)
+	}
+	return &t, nil
+}
+
+// wantChunks reports whether the caller asked for per-chunk sentiment scores
+// with ?chunks=true
+func wantChunks(c *gin.Context) bool {
+	include, _ := strconv.ParseBool(c.Query("chunks"))
+	return include
+}
+
+// archiveArticles persists fetched articles to the news archive asynchronously
+func archiveArticles(symbol string, articles []NewsClient.NewsArticle) {
+	if len(articles) == 0 {
+		return
+	}
+	go func() {
+		dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		if _, err := database.ArchiveNewsArticles(dbCtx, symbol, articles); err != nil {
+			log.Printf("Failed to archive news for %q: %v", symbol, err)
+		}
+	}()
+}
+
+// archiveProviderRated archives articles like archiveArticles, then scores
+// the new ones that carry the provider's own sentiment with FinBERT and
+// stores both for each linked ticker, as /news/general/sentiment does
+func archiveProviderRated(symbol string, articles []NewsClient.NewsArticle) {
+	if len(articles) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		archived, err := database.ArchiveNewsArticles(ctx, symbol, articles)
+		if err != nil {
+			log.Printf("Failed to archive news for %q: %v", symbol, err)
+		}
+		var rated []NewsClient.NewsArticle
+		for _, article := range archived {
+			if article.ProviderSentiment != nil {
+				rated = append(rated, article)
+			}
+		}
+		if len(rated) == 0 || !BertInference.IsInitialized() {
+			return
+		}
+
+		sentiments, err := sentAnalysis.AnalyzeArticles(ctx, rated)
+		if err != nil {
+			log.Printf("Failed to analyze sentiment for %s articles: %v", symbol, err)
+			return
+		}
+		for i, sentiment := range sentiments {
+			for _, ticker := range SymbolLinker.WithSymbol(symbol, rated[i].Symbols) {
+				if err := database.SaveArticleSentiment(ctx, ticker, &rated[i], &sentiment.BERTSentiment); err != nil {
+					log.Printf("Failed to save sentiment for %s: %v", ticker, err)
+				}
+			}
+		}
+	}()
+}
+
 // GetGeneralNewsWithSentiment godoc
 // @Summary      Get general market news with FinBERT sentiment analysis
 // @Description  Returns general market news with sentiment analysis applied to each article
 // @Tags         news
 // @Param        category   query  string  false  "News category: general, forex, crypto, merger (default: general)"
 // @Param        limit      query  int     false  "Number of articles to analyze (default: 5, max: 10)"
+// @Param        chunks     query  bool    false  "Include per-chunk scores for each article"
+// @Param        async      query  bool    false  "Run as a background job and respond 202 with its links"
 // @Produce      json
 // @Success      200  {object}  interface{}
+// @Success      202  {object}  interface{}
 // @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
 // @Router       /news/general/sentiment [get]
 func GetGeneralNewsWithSentiment(c *gin.Context) {
-	ctx := c.Request.Context()
 	category := c.DefaultQuery("category", "general")
 	limitStr := c.DefaultQuery("limit", "5")
 
@@ -704,69 +1256,541 @@ func GetGeneralNewsWithSentiment(c *gin.Context) {
 		limit = 10
 	}
 
-	articles, err := newsClient.GetGeneralMarketNews(ctx, category, limit)
+	includeChunks := wantChunks(c)
+	params := gin.H{"category": category, "limit": limit, "chunks": includeChunks}
+
+	runAnalysis(c, "general_news_sentiment", params, func(ctx context.Context) (any, error) {
+		articles, err := newsClient.GetGeneralMarketNews(ctx, category, limit)
+		if err != nil {
+			return nil, err
+		}
+		archiveArticles("", articles)
+
+		// Apply FinBERT sentiment in a single batch
+		type ArticleWithSentiment struct {
+			NewsClient.NewsArticle
+			Sentiment *BertInference.DocumentSentiment `json:"sentiment"`
+		}
+
+		articlesWithSentiment := make([]ArticleWithSentiment, len(articles))
+		for i, article := range articles {
+			articlesWithSentiment[i] = ArticleWithSentiment{NewsArticle: article}
+		}
+
+		docs := make([]BertInference.Document, len(articles))
+		for i, article := range articles {
+			docs[i] = sentAnalysis.NewsDocument(article.Title, article.Description)
+		}
+		if sentiments, err := sentimentcache.RunDocuments(ctx, "", docs); err != nil {
+			log.Printf("Failed to analyze sentiment for articles: %v", err)
+		} else {
+			shadowItems := make([]shadow.Item, len(sentiments))
+			for i, sentiment := range sentiments {
+				if !includeChunks {
+					sentiment.Chunks = nil
+				}
+				articlesWithSentiment[i].Sentiment = sentiment
+				shadowItems[i] = shadow.Item{
+					Link:       articles[i].URL,
+					Symbols:    articles[i].Symbols,
+					Document:   docs[i],
+					Production: sentiment.BERTSentiment,
+				}
+			}
+			shadow.Submit(shadowItems)
+		}
+
+		// Calculate aggregate sentiment
+		var totalScore float64
+		var totalConfidence float64
+		sentimentCounts := make(map[string]int)
+
+		for _, article := range articlesWithSentiment {
+			if article.Sentiment != nil {
+				totalScore += article.Sentiment.Score
+				totalConfidence += article.Sentiment.Confidence
+				sentimentCounts[article.Sentiment.Label]++
+			}
+		}
+
+		avgScore := 0.0
+		avgConfidence := 0.0
+		if len(articlesWithSentiment) > 0 {
+			avgScore = totalScore / float64(len(articlesWithSentiment))
+			avgConfidence = totalConfidence / float64(len(articlesWithSentiment))
+		}
+
+		// Attribute each article's sentiment to every ticker it mentions
+		byTicker := make(map[string][]BertInference.BERTSentiment)
+		for _, article := range articlesWithSentiment {
+			if article.Sentiment == nil {
+				continue
+			}
+			for _, ticker := range article.Symbols {
+				byTicker[ticker] = append(byTicker[ticker], article.Sentiment.BERTSentiment)
+			}
+		}
+		tickerSentiment := make(map[string]*sentAnalysis.StockSentimentAnalysis, len(byTicker))
+		for ticker, sentiments := range byTicker {
+			tickerSentiment[ticker] = sentAnalysis.AggregateSentiments(sentiments)
+			tickerSentiment[ticker].Symbol = ticker
+		}
+
+		// Persist per-ticker sentiment asynchronously
+		go func() {
+			dbCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			for _, article := range articlesWithSentiment {
+				if article.Sentiment == nil {
+					continue
+				}
+				for _, ticker := range article.Symbols {
+					if err := database.SaveArticleSentiment(dbCtx, ticker, &article.NewsArticle, &article.Sentiment.BERTSentiment); err != nil {
+						log.Printf("Failed to save sentiment for %s: %v", ticker, err)
+					}
+				}
+			}
+		}()
+
+		return gin.H{
+			"category": category,
+			"count":    len(articlesWithSentiment),
+			"aggregate_sentiment": gin.H{
+				"average_score":      avgScore,
+				"average_confidence": avgConfidence,
+				"sentiment_counts":   sentimentCounts,
+			},
+			"ticker_sentiment": tickerSentiment,
+			"articles":         articlesWithSentiment,
+		}, nil
+	})
+}
+
+// GetSentimentModelAgreement godoc
+// @Summary      Compare FinBERT with provider sentiment
+// @Description  Returns daily agreement between FinBERT and Alpha Vantage's native per-ticker sentiment for a symbol, plus a summary for the whole period (the row without a date)
+// @Tags         sentiment
+// @Param        symbol  path   string  true   "Ticker symbol (e.g., AAPL)"
+// @Param        days    query  int     false  "Days of history (default: 30, max: 365)"
+// @Produce      json
+// @Success      200  {object}  interface{}
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /sentiment/{symbol}/agreement [get]
+func GetSentimentModelAgreement(c *gin.Context) {
+	symbol := SymbolLinker.NormalizeSymbol(c.Param("symbol"))
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	rows, err := database.GetModelAgreement(c.Request.Context(), symbol, days)
+	if errors.Is(err, database.ErrDatabaseNotConnected) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Apply FinBERT sentiment via worker pool
-	type ArticleWithSentiment struct {
-		NewsClient.NewsArticle
-		Sentiment *BertInference.BERTSentiment `json:"sentiment"`
+	var summary *database.ModelAgreement
+	daily := make([]database.ModelAgreement, 0, len(rows))
+	for i := range rows {
+		if rows[i].Date == nil {
+			summary = &rows[i]
+			continue
+		}
+		daily = append(daily, rows[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":   symbol,
+		"days":     days,
+		"provider": "alpha_vantage",
+		"summary":  summary,
+		"daily":    daily,
+	})
+}
+
+// GetShadowComparison godoc
+// @Summary      Compare shadow models with production
+// @Description  For each candidate model that shadowed production on this symbol's articles: agreement rate, label confusion matrix (production label → candidate label → count), average scores, score drift (mean candidate − production score), mean absolute difference and correlation
+// @Tags         sentiment
+// @Param        symbol  path   string  true   "Ticker symbol (e.g., AAPL)"
+// @Param        days    query  int     false  "Days of history (default: 30, max: 365)"
+// @Param        model   query  string  false  "Only this candidate (name@version)"
+// @Produce      json
+// @Success      200  {object}  interface{}
+// @Failure      500  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /sentiment/{symbol}/shadow [get]
+func GetShadowComparison(c *gin.Context) {
+	symbol := SymbolLinker.NormalizeSymbol(c.Param("symbol"))
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 {
+		days = 30
+	}
+	if days > 365 {
+		days = 365
 	}
 
-	articlesWithSentiment := make([]ArticleWithSentiment, len(articles))
-	for i, article := range articles {
-		articlesWithSentiment[i] = ArticleWithSentiment{NewsArticle: article}
+	comparisons, err := database.GetShadowComparison(c.Request.Context(), symbol, c.Query("model"), days)
+	if errors.Is(err, database.ErrDatabaseNotConnected) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if comparisons == nil {
+		comparisons = []database.ShadowComparison{}
 	}
 
-	tasks := make([]workerpool.Task, len(articles))
-	for i, article := range articles {
-		idx := i
-		title := article.Title
-		desc := article.Description
-		tasks[i] = func(_ context.Context) error {
-			sentiment, err := sentAnalysis.AnalyzeNews(title, desc)
-			if err != nil {
-				log.Printf("Failed to analyze sentiment for article: %v", err)
-				return nil // non-fatal
-			}
-			articlesWithSentiment[idx].Sentiment = sentiment
-			return nil
+	c.JSON(http.StatusOK, gin.H{
+		"symbol":      symbol,
+		"days":        days,
+		"comparisons": comparisons,
+	})
+}
+
+// ListModels godoc
+// @Summary      List sentiment models
+// @Description  Returns every model loaded from the model manifest and which one is the default. Requires the ADMIN_TOKEN bearer token.
+// @Tags         admin
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  interface{}
+// @Failure      401  {object}  ErrorResponse
+// @Router       /admin/models [get]
+func ListModels(c *gin.Context) {
+	models := BertInference.Models()
+	c.JSON(http.StatusOK, gin.H{
+		"count":  len(models),
+		"models": models,
+	})
+}
+
+// ReloadModels godoc
+// @Summary      Reload sentiment models
+// @Description  Re-reads the model manifest and swaps in the new models without a restart. Unchanged models are kept unless force=true. Requests already running finish on the model they started with. Empties the in-process inference cache. Requires the ADMIN_TOKEN bearer token.
+// @Tags         admin
+// @Param        force  query  bool  false  "Reload every model, even if its manifest entry is unchanged"
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  interface{}
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/models/reload [post]
+func ReloadModels(c *gin.Context) {
+	force, _ := strconv.ParseBool(c.Query("force"))
+	models, err := BertInference.ReloadModels(force)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("reload failed, keeping current models: %v", err)})
+		return
+	}
+	// Results are keyed by model fingerprint, so those of replaced models
+	// can no longer be hit; drop them rather than let them age out
+	sentimentcache.Purge()
+	c.JSON(http.StatusOK, gin.H{
+		"count":  len(models),
+		"models": models,
+	})
+}
+
+// cacheSelection reads which cached responses an admin request is about:
+// exactly one of tag, symbol, endpoint (a cache policy prefix) or pattern.
+// It returns the tag, or the key pattern when selecting by pattern.
+// Patterns must stay within cached responses so they cannot reach jobs or
+// other records kept in Redis.
+func cacheSelection(c *gin.Context) (tag, pattern string, err error) {
+	var selected []string
+	if v := c.Query("tag"); v != "" {
+		tag, selected = v, append(selected, "tag")
+	}
+	if v := c.Query("symbol"); v != "" {
+		tag, selected = middleware.SymbolTag(v), append(selected, "symbol")
+	}
+	if v := c.Query("endpoint"); v != "" {
+		tag, selected = middleware.EndpointTag(v), append(selected, "endpoint")
+	}
+	if v := c.Query("pattern"); v != "" {
+		pattern, selected = v, append(selected, "pattern")
+	}
+	if len(selected) > 1 {
+		return "", "", fmt.Errorf("use only one of %s", strings.Join(selected, ", "))
+	}
+	if err := checkCacheKey("pattern", pattern); err != nil {
+		return "", "", err
+	}
+	return tag, pattern, nil
+}
+
+// checkCacheKey rejects a key or pattern outside the cached responses
+func checkCacheKey(name, value string) error {
+	if value != "" && !strings.HasPrefix(value, middleware.CacheKeyPrefix) {
+		return fmt.Errorf("%s must start with %q", name, middleware.CacheKeyPrefix)
+	}
+	return nil
+}
+
+// ListCacheKeys godoc
+// @Summary      List cached keys
+// @Description  Lists the keys of cached responses under a tag (tag, symbol or endpoint) or matching a glob pattern (default "cache:*"). Requires the ADMIN_TOKEN bearer token.
+// @Tags         admin
+// @Param        tag       query  string  false  "Tag, e.g. symbol:AAPL or endpoint:/quote/"
+// @Param        symbol    query  string  false  "Shorthand for tag=symbol:<symbol>"
+// @Param        endpoint  query  string  false  "Shorthand for tag=endpoint:<prefix>"
+// @Param        pattern   query  string  false  "Glob pattern over keys (* and ?), starting with cache:"
+// @Param        limit     query  int     false  "Maximum keys to return (default 1000)"
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  interface{}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/cache/keys [get]
+func ListCacheKeys(c *gin.Context) {
+	tag, pattern, err := cacheSelection(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit := 1000
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
 		}
 	}
 
-	workerpool.Run(ctx, 3, tasks)
+	var keys []string
+	if tag != "" {
+		keys, err = cache.TaggedKeys(c.Request.Context(), tag)
+	} else {
+		if pattern == "" {
+			pattern = middleware.CacheKeyPrefix + "*"
+		}
+		// One extra key tells whether the list was cut short
+		keys, err = cache.Keys(c.Request.Context(), pattern, limit+1)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Calculate aggregate sentiment
-	var totalScore float64
-	var totalConfidence float64
-	sentimentCounts := make(map[string]int)
+	slices.Sort(keys)
+	truncated := len(keys) > limit
+	if truncated {
+		keys = keys[:limit]
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tag":       tag,
+		"pattern":   pattern,
+		"count":     len(keys),
+		"truncated": truncated,
+		"keys":      keys,
+	})
+}
+
+// GetCacheEntry godoc
+// @Summary      Inspect a cached response
+// @Description  Returns a cached response with its status, headers, ETag, tags, freshness and body. Requires the ADMIN_TOKEN bearer token.
+// @Tags         admin
+// @Param        key  query  string  true  "Cache key, e.g. cache:/quote/AAPL"
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  middleware.CachedResponse
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/cache/entry [get]
+func GetCacheEntry(c *gin.Context) {
+	key := c.Query("key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+	if err := checkCacheKey("key", key); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry, err := middleware.InspectCachedResponse(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if entry == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "key not cached"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
 
-	for _, article := range articlesWithSentiment {
-		if article.Sentiment != nil {
-			totalScore += article.Sentiment.Score
-			totalConfidence += article.Sentiment.Confidence
-			sentimentCounts[article.Sentiment.Label]++
+// PurgeCache godoc
+// @Summary      Purge cached responses
+// @Description  Deletes one key, every key under a tag (tag, symbol or endpoint), or every key matching a glob pattern, from Redis and from the in-process cache of every replica. Requires the ADMIN_TOKEN bearer token.
+// @Tags         admin
+// @Param        key       query  string  false  "Single cache key, starting with cache:"
+// @Param        tag       query  string  false  "Tag, e.g. symbol:AAPL or endpoint:/quote/"
+// @Param        symbol    query  string  false  "Shorthand for tag=symbol:<symbol>"
+// @Param        endpoint  query  string  false  "Shorthand for tag=endpoint:<prefix>"
+// @Param        pattern   query  string  false  "Glob pattern over keys (* and ?), starting with cache:"
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  interface{}
+// @Failure      400  {object}  ErrorResponse
+// @Failure      401  {object}  ErrorResponse
+// @Failure      500  {object}  ErrorResponse
+// @Router       /admin/cache [delete]
+func PurgeCache(c *gin.Context) {
+	tag, pattern, err := cacheSelection(c)
+	key := c.Query("key")
+	switch {
+	case err != nil:
+	case key != "" && (tag != "" || pattern != ""):
+		err = fmt.Errorf("use only one of key, tag, symbol, endpoint, pattern")
+	case key == "" && tag == "" && pattern == "":
+		err = fmt.Errorf("one of key, tag, symbol, endpoint or pattern is required")
+	default:
+		err = checkCacheKey("key", key)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var deleted int
+	switch {
+	case key != "":
+		deleted, err = cache.DeleteMany(ctx, []string{key})
+	case tag != "":
+		deleted, err = cache.PurgeTag(ctx, tag)
+	default:
+		var keys []string
+		if keys, err = cache.Keys(ctx, pattern, 0); err == nil {
+			deleted, err = cache.DeleteMany(ctx, keys)
 		}
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "deleted": deleted})
+		return
+	}
+	log.Printf("Cache purge (key=%q tag=%q pattern=%q): %d keys deleted", key, tag, pattern, deleted)
+	c.JSON(http.StatusOK, gin.H{
+		"key":     key,
+		"tag":     tag,
+		"pattern": pattern,
+		"deleted": deleted,
+	})
+}
+
+// SetScheduler makes the background job scheduler available to the /jobs endpoints
+func SetScheduler(s *scheduler.Scheduler) {
+	jobScheduler = s
+}
 
-	avgScore := 0.0
-	avgConfidence := 0.0
-	if len(articlesWithSentiment) > 0 {
-		avgScore = totalScore / float64(len(articlesWithSentiment))
-		avgConfidence = totalConfidence / float64(len(articlesWithSentiment))
+// ListJobSchedules godoc
+// @Summary      List scheduled jobs
+// @Description  Returns every background ingestion job with its schedule, next activation and last run
+// @Tags         jobs
+// @Produce      json
+// @Success      200  {object}  interface{}
+// @Failure      503  {object}  ErrorResponse
+// @Router       /jobs/schedules [get]
+func ListJobSchedules(c *gin.Context) {
+	if jobScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not running (set INGEST_SYMBOLS to enable)"})
+		return
 	}
 
+	jobs := jobScheduler.Status()
 	c.JSON(http.StatusOK, gin.H{
-		"category": category,
-		"count":    len(articlesWithSentiment),
-		"aggregate_sentiment": gin.H{
-			"average_score":      avgScore,
-			"average_confidence": avgConfidence,
-			"sentiment_counts":   sentimentCounts,
-		},
-		"articles": articlesWithSentiment,
+		"count": len(jobs),
+		"jobs":  jobs,
+	})
+}
+
+// GetJobSchedule godoc
+// @Summary      Get a scheduled job
+// @Description  Returns a job's status together with its recent runs and failures from job_runs
+// @Tags         jobs
+// @Param        name   path   string  true   "Job name (e.g., quotes)"
+// @Param        limit  query  int     false  "Number of recent runs (default: 20, max: 100)"
+// @Produce      json
+// @Success      200  {object}  interface{}
+// @Failure      404  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /jobs/schedules/{name} [get]
+func GetJobSchedule(c *gin.Context) {
+	if jobScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not running (set INGEST_SYMBOLS to enable)"})
+		return
+	}
+
+	status, err := jobScheduler.JobStatus(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	runs, err := database.GetJobRuns(c.Request.Context(), status.Name, limit)
+	if err != nil && !errors.Is(err, database.ErrDatabaseNotConnected) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job":         status,
+		"recent_runs": runs,
 	})
 }
+
+// RunJobSchedule godoc
+// @Summary      Trigger a scheduled job
+// @Description  Starts a job immediately in the background; poll /jobs/schedules/{name} for the result. Requires the ADMIN_TOKEN bearer token.
+// @Tags         admin
+// @Param        name  path  string  true  "Job name (e.g., quotes)"
+// @Produce      json
+// @Security     BearerAuth
+// @Success      202  {object}  interface{}
+// @Failure      401  {object}  ErrorResponse
+// @Failure      404  {object}  ErrorResponse
+// @Failure      409  {object}  ErrorResponse
+// @Failure      503  {object}  ErrorResponse
+// @Router       /admin/jobs/schedules/{name}/run [post]
+func RunJobSchedule(c *gin.Context) {
+	if jobScheduler == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "scheduler not running (set INGEST_SYMBOLS to enable)"})
+		return
+	}
+
+	name := c.Param("name")
+	switch err := jobScheduler.RunNow(name); {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, scheduler.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"job": name, "status": "started"})
+	}
+}
@@ -6,6 +6,10 @@ package main
 // @host        localhost:${PORT}
 // @BasePath    /
 // @schemes     http
+// @securityDefinitions.apikey BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer " followed by ADMIN_TOKEN
 
 import (
 	"context"
@@ -20,8 +24,16 @@ import (
 	"github.com/MadebyDaris/dogonomics/controller"
 	"github.com/MadebyDaris/dogonomics/docs"
 	"github.com/MadebyDaris/dogonomics/internal/DogonomicsFetching"
+	"github.com/MadebyDaris/dogonomics/internal/NewsClient"
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
+	"github.com/MadebyDaris/dogonomics/internal/bulk"
 	"github.com/MadebyDaris/dogonomics/internal/cache"
 	"github.com/MadebyDaris/dogonomics/internal/database"
+	"github.com/MadebyDaris/dogonomics/internal/ingestion"
+	"github.com/MadebyDaris/dogonomics/internal/jobs"
+	"github.com/MadebyDaris/dogonomics/internal/scheduler"
+	"github.com/MadebyDaris/dogonomics/internal/sentimentcache"
+	"github.com/MadebyDaris/dogonomics/internal/shadow"
 	"github.com/MadebyDaris/dogonomics/middleware"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -54,16 +66,60 @@ func main() {
 		if err := database.HealthCheck(ctx); err != nil {
 			log.Printf("WARNING: Database health check failed: %v", err)
 		}
+
+		// Seed the news ticker dictionary from stored company profiles
+		if names, err := database.GetCompanyNames(ctx); err != nil {
+			log.Printf("WARNING: Failed to load company names for symbol linking: %v", err)
+		} else {
+			companies := make([]SymbolLinker.Company, 0, len(names))
+			for symbol, name := range names {
+				companies = append(companies, SymbolLinker.Company{Symbol: symbol, Name: name})
+			}
+			SymbolLinker.Default.Load(companies)
+			log.Printf("Symbol linker loaded %d companies", len(companies))
+		}
+
+		if n, err := database.AbandonRunningJobs(ctx); err != nil {
+			log.Printf("WARNING: Failed to clean up interrupted job runs: %v", err)
+		} else if n > 0 {
+			log.Printf("Marked %d interrupted job runs as failed", n)
+		}
 	}
 
 	if err := cache.Connect(cache.LoadConfigFromEnv()); err != nil {
 		log.Printf("WARNING: Redis connection failed: %v", err)
-		log.Printf("API will continue without caching")
+		log.Printf("API will continue with in-process caching only")
 	}
+	// The in-process layer works without Redis
+	sentimentcache.Init(sentimentcache.LoadConfigFromEnv())
 
-	fmt.Println("Initializing BERT model...")
-	modelPath := "./sentAnalysis/DoggoFinBERT.onnx"
-	vocabPath := "./sentAnalysis/finbert/vocab.txt"
+	// Refuse to start with a broken cache policy; later edits that break
+	// it are logged and ignored
+	cachePolicy, err := middleware.LoadCachePolicy(os.Getenv("CACHE_POLICY_FILE"))
+	if err != nil {
+		log.Fatalf("Failed to load cache policy: %v", err)
+	}
+	stopPolicyWatch := cachePolicy.Watch()
+
+	fmt.Println("Initializing BERT models...")
+	modelsFile := os.Getenv("BERT_MODELS_FILE")
+	if modelsFile == "" {
+		modelsFile = "./sentAnalysis/models.json"
+	}
+
+	var jobScheduler *scheduler.Scheduler
+	ingestCfg := ingestion.LoadConfigFromEnv()
+	if jobs := ingestion.Jobs(ingestCfg, finnhubClient, NewsClient.NewNewsClient()); len(jobs) > 0 {
+		jobScheduler = scheduler.New()
+		for _, job := range jobs {
+			if err := jobScheduler.Add(job); err != nil {
+				log.Printf("WARNING: Skipping ingestion job: %v", err)
+			}
+		}
+		controller.SetScheduler(jobScheduler)
+	} else {
+		log.Println("INGEST_SYMBOLS not set, background ingestion disabled")
+	}
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
@@ -71,6 +127,12 @@ func main() {
 	go func() {
 		<-c
 		fmt.Println("\nShutting down server...")
+		if jobScheduler != nil {
+			jobScheduler.Stop()
+		}
+		shadow.Stop()
+		jobs.Stop()
+		stopPolicyWatch()
 		cache.Close()
 		database.Close()
 		BertInference.CleanupBERT()
@@ -78,12 +140,22 @@ func main() {
 		os.Exit(0)
 	}()
 
-	if err := BertInference.InitializeBERT(modelPath, vocabPath); err != nil {
+	if err := BertInference.InitializeBERT(modelsFile); err != nil {
 		log.Printf("ERROR: Failed to initialize BERT model: %v", err)
 		log.Printf("Sentiment analysis features will be disabled")
 		log.Printf("Server will continue without sentiment analysis")
 	} else {
-		fmt.Println("BERT model initialized successfully")
+		fmt.Println("Sentiment engine initialized successfully")
+	}
+
+	shadow.Start(shadow.LoadConfigFromEnv())
+	bulk.Init(bulk.LoadConfigFromEnv())
+	jobs.Start(jobs.LoadConfigFromEnv())
+
+	// Start after BERT so the first sentiment run can use the model
+	if jobScheduler != nil {
+		jobScheduler.Start()
+		log.Printf("Background ingestion started for %d symbols", len(ingestCfg.Symbols))
 	}
 
 	gin.SetMode(gin.ReleaseMode)
@@ -112,9 +184,15 @@ func main() {
 	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration)
 	// Middleware
 	r.Use(middleware.DatabaseLogger())
-	r.Use(middleware.CacheMiddleware(5 * time.Minute))
+	r.Use(middleware.CacheMiddleware(r, cachePolicy))
 
 	r.Use(func(c *gin.Context) {
+		// Background cache refreshes are not client requests
+		if middleware.IsRefresh(c) {
+			c.Next()
+			return
+		}
+
 		start := time.Now()
 		c.Next()
 
@@ -156,20 +234,14 @@ func main() {
 
 	r.GET("/swagger/*any", gin.WrapH(httpSwagger.Handler(httpSwagger.URL("http://localhost:"+port+"/swagger/doc.json"))))
 
-	r.Use(func(c *gin.Context) {
-		path := c.Request.URL.Path
-		if path == "/finnewsBert/"+c.Param("symbol") || path == "/sentiment/"+c.Param("symbol") {
-			c.Header("X-Request-Timeout", "60s")
-		}
-		c.Next()
-	})
-
 	// Stock & market data
 	r.GET("/ticker/:symbol", controller.GetTicker)
 	r.GET("/quote/:symbol", controller.GetQuote)
 	r.GET("/finnews/:symbol", controller.GetNews)
 	r.GET("/finnewsBert/:symbol", controller.GetNewsSentimentBERT)
 	r.GET("/sentiment/:symbol", controller.GetSentimentOnly)
+	r.GET("/sentiment/:symbol/agreement", controller.GetSentimentModelAgreement)
+	r.GET("/sentiment/:symbol/shadow", controller.GetShadowComparison)
 	r.GET("/stock/:symbol", controller.GetStockDetail)
 	r.GET("/profile/:symbol", controller.GetCompanyProfile)
 	r.GET("/chart/:symbol", controller.GetChartData)
@@ -177,6 +249,8 @@ func main() {
 
 	// Sentiment
 	r.POST("/finbert/inference", controller.RunFinBertInference)
+	r.POST("/finbert/batch", controller.SubmitBatchInference)
+	r.GET("/finbert/batch/:id/results", controller.GetBatchInferenceResults)
 
 	// News
 	r.GET("/news/general", controller.GetGeneralFinanceNews)
@@ -184,6 +258,23 @@ func main() {
 	r.GET("/news/symbol/:symbol", controller.GetNewsBySymbolMultiSource)
 	r.GET("/news/search", controller.SearchNews)
 
+	// Background jobs
+	r.GET("/jobs/:id", controller.GetJob)
+	r.DELETE("/jobs/:id", controller.CancelJob)
+	r.GET("/jobs/:id/events", controller.StreamJob)
+	r.GET("/jobs/:id/result", controller.GetJobResult)
+	r.GET("/jobs/schedules", controller.ListJobSchedules)
+	r.GET("/jobs/schedules/:name", controller.GetJobSchedule)
+
+	// Admin (ADMIN_TOKEN bearer auth)
+	admin := r.Group("/admin", middleware.AdminAuth())
+	admin.GET("/models", controller.ListModels)
+	admin.POST("/models/reload", controller.ReloadModels)
+	admin.POST("/jobs/schedules/:name/run", controller.RunJobSchedule)
+	admin.GET("/cache/keys", controller.ListCacheKeys)
+	admin.GET("/cache/entry", controller.GetCacheEntry)
+	admin.DELETE("/cache", controller.PurgeCache)
+
 	// Treasury
 	r.GET("/treasury/yield-curve", controller.GetTreasuryYieldCurve)
 	r.GET("/treasury/rates", controller.GetTreasuryRates)
@@ -5,12 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
 )
 
 // NewsArticle represents a standardized news article from any source
@@ -24,6 +27,41 @@ type NewsArticle struct {
 	Author      string    `json:"author,omitempty"`
 	ImageURL    string    `json:"image_url,omitempty"`
 	Category    string    `json:"category,omitempty"`
+	Symbols     []string  `json:"symbols,omitempty"` // tickers the article refers to
+
+	// Sentiment scored by the news provider itself, when it offers one
+	ProviderSentiment *ProviderSentiment `json:"provider_sentiment,omitempty"`
+}
+
+// ProviderSentiment is a news provider's own sentiment scoring of an article.
+// Alpha Vantage scores range from -1 (bearish) to 1 (bullish).
+type ProviderSentiment struct {
+	Provider     string            `json:"provider"`
+	OverallScore float64           `json:"overall_score"`
+	OverallLabel string            `json:"overall_label"`
+	Tickers      []TickerSentiment `json:"tickers,omitempty"`
+}
+
+// TickerSentiment is the provider's sentiment towards one ticker in an article
+type TickerSentiment struct {
+	Ticker    string  `json:"ticker"`
+	Relevance float64 `json:"relevance"`
+	Score     float64 `json:"score"`
+	Label     string  `json:"label"`
+}
+
+// ForTicker returns the provider's sentiment towards the given ticker, or
+// nil if the provider did not score it.
+func (ps *ProviderSentiment) ForTicker(symbol string) *TickerSentiment {
+	if ps == nil {
+		return nil
+	}
+	for i := range ps.Tickers {
+		if ps.Tickers[i].Ticker == symbol {
+			return &ps.Tickers[i]
+		}
+	}
+	return nil
 }
 
 // NewsClient handles fetching news from multiple sources
@@ -31,14 +69,38 @@ type NewsClient struct {
 	finnhubKey string
 	eodhKey    string
 	alphaKey   string
+	feeds      []FeedConfig
+	linker     *SymbolLinker.Linker
 }
 
 // NewNewsClient creates a new multi-source news client
 func NewNewsClient() *NewsClient {
-	return &NewsClient{
+	nc := &NewsClient{
 		finnhubKey: os.Getenv("FINNHUB_API_KEY"),
 		eodhKey:    os.Getenv("EODHD_API_KEY"),
 		alphaKey:   os.Getenv("ALPHA_VANTAGE_API_KEY"),
+		linker:     SymbolLinker.Default,
+	}
+
+	if path := os.Getenv("RSS_FEEDS_FILE"); path != "" {
+		feeds, err := LoadFeedConfig(path)
+		if err != nil {
+			log.Printf("RSS feeds disabled: %v", err)
+		} else {
+			nc.feeds = feeds
+			log.Printf("Following %d RSS/Atom feeds", len(feeds))
+		}
+	}
+
+	return nc
+}
+
+// linkSymbols tags each article with the tickers it mentions, merging the
+// provider's own symbol metadata with dictionary matches on the text.
+func (nc *NewsClient) linkSymbols(articles []NewsArticle) {
+	for i := range articles {
+		text := articles[i].Title + ". " + articles[i].Description
+		articles[i].Symbols = nc.linker.Link(text, articles[i].Symbols...)
 	}
 }
 
@@ -78,6 +140,18 @@ func (nc *NewsClient) GetGeneralMarketNews(ctx context.Context, category string,
 		}()
 	}
 
+	// Fetch from configured RSS/Atom feeds
+	if len(nc.feeds) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			feedNews := nc.fetchFeeds(ctx, nc.feeds, limit)
+			mu.Lock()
+			allNews = append(allNews, feedNews...)
+			mu.Unlock()
+		}()
+	}
+
 	wg.Wait()
 
 	if ctx.Err() != nil {
@@ -93,6 +167,7 @@ func (nc *NewsClient) GetGeneralMarketNews(ctx context.Context, category string,
 		allNews = allNews[:limit]
 	}
 
+	nc.linkSymbols(allNews)
 	return allNews, nil
 }
 
@@ -144,6 +219,18 @@ func (nc *NewsClient) GetNewsBySymbol(ctx context.Context, symbol string, limit
 		}()
 	}
 
+	// Feeds mapped to this ticker, e.g. the company's IR page
+	if symbolFeeds := nc.symbolFeeds(symbol); len(symbolFeeds) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			feedNews := nc.fetchFeeds(ctx, symbolFeeds, limit)
+			mu.Lock()
+			allNews = append(allNews, feedNews...)
+			mu.Unlock()
+		}()
+	}
+
 	wg.Wait()
 
 	if ctx.Err() != nil {
@@ -158,6 +245,7 @@ func (nc *NewsClient) GetNewsBySymbol(ctx context.Context, symbol string, limit
 		allNews = allNews[:limit]
 	}
 
+	nc.linkSymbols(allNews)
 	return allNews, nil
 }
 
@@ -204,6 +292,24 @@ func (nc *NewsClient) GetNewsByKeyword(ctx context.Context, keyword string, limi
 		}()
 	}
 
+	// RSS/Atom feeds - filter entries by keyword
+	if len(nc.feeds) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var filtered []NewsArticle
+			for _, article := range nc.fetchFeeds(ctx, nc.feeds, limit*2) {
+				if strings.Contains(strings.ToLower(article.Title), strings.ToLower(keyword)) ||
+					strings.Contains(strings.ToLower(article.Description), strings.ToLower(keyword)) {
+					filtered = append(filtered, article)
+				}
+			}
+			mu.Lock()
+			allNews = append(allNews, filtered...)
+			mu.Unlock()
+		}()
+	}
+
 	wg.Wait()
 
 	if ctx.Err() != nil {
@@ -218,6 +324,7 @@ func (nc *NewsClient) GetNewsByKeyword(ctx context.Context, keyword string, limi
 		allNews = allNews[:limit]
 	}
 
+	nc.linkSymbols(allNews)
 	return allNews, nil
 }
 
@@ -279,6 +386,7 @@ func (nc *NewsClient) fetchFinnhubGeneralNews(ctx context.Context, category stri
 			PublishedAt: time.Unix(item.Datetime, 0),
 			ImageURL:    item.Image,
 			Category:    item.Category,
+			Symbols:     SymbolLinker.SplitSymbols(item.Related),
 		})
 	}
 
@@ -344,6 +452,7 @@ func (nc *NewsClient) fetchFinnhubCompanyNews(ctx context.Context, symbol string
 			PublishedAt: time.Unix(item.Datetime, 0),
 			ImageURL:    item.Image,
 			Category:    item.Category,
+			Symbols:     SymbolLinker.SplitSymbols(item.Related),
 		})
 	}
 
@@ -376,11 +485,11 @@ func (nc *NewsClient) fetchEODHDNews(ctx context.Context, symbol string, limit i
 	}
 
 	var eodhResp []struct {
-		Date    string `json:"date"`
-		Title   string `json:"title"`
-		Content string `json:"content"`
-		Link    string `json:"link"`
-		Symbols string `json:"symbols"`
+		Date    string                  `json:"date"`
+		Title   string                  `json:"title"`
+		Content string                  `json:"content"`
+		Link    string                  `json:"link"`
+		Symbols SymbolLinker.SymbolList `json:"symbols"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&eodhResp); err != nil {
@@ -397,6 +506,7 @@ func (nc *NewsClient) fetchEODHDNews(ctx context.Context, symbol string, limit i
 			Source:      "EODHD",
 			URL:         item.Link,
 			PublishedAt: publishedAt,
+			Symbols:     []string(item.Symbols),
 		})
 	}
 
@@ -439,17 +549,8 @@ func (nc *NewsClient) fetchAlphaVantageNews(ctx context.Context, symbol string,
 	}
 
 	var alphaResp struct {
-		Items string `json:"items"`
-		Feed  []struct {
-			Title                string   `json:"title"`
-			URL                  string   `json:"url"`
-			TimePublished        string   `json:"time_published"`
-			Authors              []string `json:"authors"`
-			Summary              string   `json:"summary"`
-			BannerImage          string   `json:"banner_image"`
-			Source               string   `json:"source"`
-			CategoryWithinSource string   `json:"category_within_source"`
-		} `json:"feed"`
+		Items string                 `json:"items"`
+		Feed  []alphaVantageFeedItem `json:"feed"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&alphaResp); err != nil {
@@ -461,26 +562,7 @@ func (nc *NewsClient) fetchAlphaVantageNews(ctx context.Context, symbol string,
 		if i >= limit {
 			break
 		}
-
-		// Parse time: format is "20241231T120000"
-		publishedAt, _ := time.Parse("20060102T150405", item.TimePublished)
-
-		author := ""
-		if len(item.Authors) > 0 {
-			author = item.Authors[0]
-		}
-
-		articles = append(articles, NewsArticle{
-			Title:       item.Title,
-			Description: item.Summary,
-			Content:     item.Summary,
-			Source:      fmt.Sprintf("Alpha Vantage (%s)", item.Source),
-			URL:         item.URL,
-			PublishedAt: publishedAt,
-			Author:      author,
-			ImageURL:    item.BannerImage,
-			Category:    item.CategoryWithinSource,
-		})
+		articles = append(articles, item.toArticle())
 	}
 
 	return articles, nil
@@ -519,17 +601,8 @@ func (nc *NewsClient) fetchAlphaVantageNewsByTopic(ctx context.Context, topic st
 	}
 
 	var alphaResp struct {
-		Items string `json:"items"`
-		Feed  []struct {
-			Title                string   `json:"title"`
-			URL                  string   `json:"url"`
-			TimePublished        string   `json:"time_published"`
-			Authors              []string `json:"authors"`
-			Summary              string   `json:"summary"`
-			BannerImage          string   `json:"banner_image"`
-			Source               string   `json:"source"`
-			CategoryWithinSource string   `json:"category_within_source"`
-		} `json:"feed"`
+		Items string                 `json:"items"`
+		Feed  []alphaVantageFeedItem `json:"feed"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&alphaResp); err != nil {
@@ -541,28 +614,78 @@ func (nc *NewsClient) fetchAlphaVantageNewsByTopic(ctx context.Context, topic st
 		if i >= limit {
 			break
 		}
+		articles = append(articles, item.toArticle())
+	}
+
+	return articles, nil
+}
 
-		publishedAt, _ := time.Parse("20060102T150405", item.TimePublished)
+// alphaVantageFeedItem is one entry of the NEWS_SENTIMENT feed, including
+// Alpha Vantage's own sentiment scoring
+type alphaVantageFeedItem struct {
+	Title                 string   `json:"title"`
+	URL                   string   `json:"url"`
+	TimePublished         string   `json:"time_published"`
+	Authors               []string `json:"authors"`
+	Summary               string   `json:"summary"`
+	BannerImage           string   `json:"banner_image"`
+	Source                string   `json:"source"`
+	CategoryWithinSource  string   `json:"category_within_source"`
+	OverallSentimentScore float64  `json:"overall_sentiment_score"`
+	OverallSentimentLabel string   `json:"overall_sentiment_label"`
+	TickerSentiment       []struct {
+		Ticker         string  `json:"ticker"`
+		RelevanceScore float64 `json:"relevance_score,string"`
+		SentimentScore float64 `json:"ticker_sentiment_score,string"`
+		SentimentLabel string  `json:"ticker_sentiment_label"`
+	} `json:"ticker_sentiment"`
+}
 
-		author := ""
-		if len(item.Authors) > 0 {
-			author = item.Authors[0]
-		}
+func (item alphaVantageFeedItem) toArticle() NewsArticle {
+	// Parse time: format is "20241231T120000"
+	publishedAt, _ := time.Parse("20060102T150405", item.TimePublished)
 
-		articles = append(articles, NewsArticle{
-			Title:       item.Title,
-			Description: item.Summary,
-			Content:     item.Summary,
-			Source:      fmt.Sprintf("Alpha Vantage (%s)", item.Source),
-			URL:         item.URL,
-			PublishedAt: publishedAt,
-			Author:      author,
-			ImageURL:    item.BannerImage,
-			Category:    item.CategoryWithinSource,
-		})
+	author := ""
+	if len(item.Authors) > 0 {
+		author = item.Authors[0]
 	}
 
-	return articles, nil
+	// Items without an overall label carry no sentiment worth storing
+	var sentiment *ProviderSentiment
+	if item.OverallSentimentLabel != "" {
+		sentiment = &ProviderSentiment{
+			Provider:     "alpha_vantage",
+			OverallScore: item.OverallSentimentScore,
+			OverallLabel: item.OverallSentimentLabel,
+		}
+	}
+	var symbols []string
+	for _, ts := range item.TickerSentiment {
+		ticker := SymbolLinker.NormalizeSymbol(ts.Ticker)
+		symbols = append(symbols, ticker)
+		if sentiment != nil {
+			sentiment.Tickers = append(sentiment.Tickers, TickerSentiment{
+				Ticker:    ticker,
+				Relevance: ts.RelevanceScore,
+				Score:     ts.SentimentScore,
+				Label:     ts.SentimentLabel,
+			})
+		}
+	}
+
+	return NewsArticle{
+		Title:             item.Title,
+		Description:       item.Summary,
+		Content:           item.Summary,
+		Source:            fmt.Sprintf("Alpha Vantage (%s)", item.Source),
+		URL:               item.URL,
+		PublishedAt:       publishedAt,
+		Author:            author,
+		ImageURL:          item.BannerImage,
+		Category:          item.CategoryWithinSource,
+		Symbols:           symbols,
+		ProviderSentiment: sentiment,
+	}
 }
 
 func min(a, b int) int {
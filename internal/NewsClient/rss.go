@@ -0,0 +1,410 @@
+package NewsClient
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
+)
+
+// FeedConfig describes one RSS or Atom feed to follow
+type FeedConfig struct {
+	Name     string   `json:"name"`               // shown as the article source
+	URL      string   `json:"url"`                // http(s) URL, file:// URL or local path
+	Symbols  []string `json:"symbols,omitempty"`  // tickers every entry is attributed to (e.g. an IR feed)
+	Category string   `json:"category,omitempty"` // used when an entry has no category of its own
+}
+
+// LoadFeedConfig reads a JSON array of feeds. Relative local paths are
+// resolved against the directory of the config file.
+func LoadFeedConfig(path string) ([]FeedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed config: %v", err)
+	}
+
+	var feeds []FeedConfig
+	if err := json.Unmarshal(data, &feeds); err != nil {
+		return nil, fmt.Errorf("failed to parse feed config %s: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for i := range feeds {
+		if feeds[i].URL == "" {
+			return nil, fmt.Errorf("feed %d in %s has no url", i, path)
+		}
+		if !strings.Contains(feeds[i].URL, "://") && !filepath.IsAbs(feeds[i].URL) {
+			feeds[i].URL = filepath.Join(dir, feeds[i].URL)
+		}
+		for j, s := range feeds[i].Symbols {
+			feeds[i].Symbols[j] = SymbolLinker.NormalizeSymbol(s)
+		}
+	}
+	return feeds, nil
+}
+
+// mentions reports whether the feed is mapped to the given ticker
+func (f FeedConfig) mentions(symbol string) bool {
+	symbol = SymbolLinker.NormalizeSymbol(symbol)
+	for _, s := range f.Symbols {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolFeeds returns the feeds mapped to the given ticker
+func (nc *NewsClient) symbolFeeds(symbol string) []FeedConfig {
+	var feeds []FeedConfig
+	for _, feed := range nc.feeds {
+		if feed.mentions(symbol) {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds
+}
+
+// GetFeedNewsBySymbol returns entries from the feeds mapped to the given
+// ticker, e.g. the company's IR page, with their linked tickers. Feeds that
+// fail are skipped.
+func (nc *NewsClient) GetFeedNewsBySymbol(ctx context.Context, symbol string, limit int) []NewsArticle {
+	feeds := nc.symbolFeeds(symbol)
+	if len(feeds) == 0 {
+		return nil
+	}
+	articles := nc.fetchFeeds(ctx, feeds, limit)
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	nc.linkSymbols(articles)
+	return articles
+}
+
+// fetchFeeds fetches the given feeds concurrently. Feeds that fail are
+// skipped so one broken blog doesn't hide the rest.
+func (nc *NewsClient) fetchFeeds(ctx context.Context, feeds []FeedConfig, limit int) []NewsArticle {
+	var (
+		mu       sync.Mutex
+		articles []NewsArticle
+		wg       sync.WaitGroup
+	)
+
+	for _, feed := range feeds {
+		wg.Add(1)
+		go func(feed FeedConfig) {
+			defer wg.Done()
+			items, err := fetchFeed(ctx, feed, limit)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			articles = append(articles, items...)
+			mu.Unlock()
+		}(feed)
+	}
+
+	wg.Wait()
+	return articles
+}
+
+// feedClient fetches remote feeds; maxFeedBytes caps how much of one
+// feed is read
+var feedClient = &http.Client{Timeout: 30 * time.Second}
+
+const maxFeedBytes = 5 << 20
+
+// fetchFeed loads a single feed over HTTP or from disk
+func fetchFeed(ctx context.Context, feed FeedConfig, limit int) ([]NewsArticle, error) {
+	u, err := url.Parse(feed.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid feed url %q: %v", feed.URL, err)
+	}
+
+	var body io.ReadCloser
+	switch u.Scheme {
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.URL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml, text/xml")
+
+		resp, err := feedClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch feed %s: %v", feed.URL, err)
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("feed %s returned %s", feed.URL, resp.Status)
+		}
+		body = resp.Body
+	case "file":
+		if body, err = os.Open(u.Path); err != nil {
+			return nil, err
+		}
+	default:
+		if body, err = os.Open(feed.URL); err != nil {
+			return nil, err
+		}
+	}
+	defer body.Close()
+
+	articles, err := ParseFeed(io.LimitReader(body, maxFeedBytes), feed)
+	if err != nil {
+		return nil, err
+	}
+	if len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles, nil
+}
+
+// ParseFeed decodes an RSS 2.0 or Atom document into articles. Entries
+// inherit the feed's symbols; text linking happens later in linkSymbols.
+func ParseFeed(r io.Reader, feed FeedConfig) ([]NewsArticle, error) {
+	var doc struct {
+		XMLName xml.Name
+		// RSS 2.0
+		Channel struct {
+			Title string    `xml:"title"`
+			Items []rssItem `xml:"item"`
+		} `xml:"channel"`
+		// Atom
+		Title   string      `xml:"title"`
+		Entries []atomEntry `xml:"entry"`
+	}
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		// Feeds commonly declare latin-1 or windows-1252 while sending ASCII
+		// or UTF-8; decode as-is rather than rejecting them outright
+		return input, nil
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode feed %s: %v", feed.URL, err)
+	}
+
+	var articles []NewsArticle
+	switch doc.XMLName.Local {
+	case "rss":
+		source := feedSource(feed, doc.Channel.Title)
+		for _, item := range doc.Channel.Items {
+			articles = append(articles, item.toArticle(source, feed))
+		}
+	case "feed":
+		source := feedSource(feed, doc.Title)
+		for _, entry := range doc.Entries {
+			articles = append(articles, entry.toArticle(source, feed))
+		}
+	default:
+		return nil, fmt.Errorf("feed %s is neither RSS nor Atom (root element <%s>)", feed.URL, doc.XMLName.Local)
+	}
+
+	// Drop entries without a headline; they can't be archived or scored
+	kept := articles[:0]
+	for _, a := range articles {
+		if a.Title != "" {
+			kept = append(kept, a)
+		}
+	}
+	return kept, nil
+}
+
+type rssItem struct {
+	Title          string   `xml:"title"`
+	Link           string   `xml:"link"`
+	GUID           string   `xml:"guid"`
+	Description    string   `xml:"description"`
+	ContentEncoded string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate        string   `xml:"pubDate"`
+	DCDate         string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author         string   `xml:"author"`
+	Creator        string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Categories     []string `xml:"category"`
+	Enclosure      struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	MediaContent struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+func (item rssItem) toArticle(source string, feed FeedConfig) NewsArticle {
+	link := strings.TrimSpace(item.Link)
+	if link == "" && strings.HasPrefix(item.GUID, "http") {
+		link = strings.TrimSpace(item.GUID)
+	}
+
+	image := item.MediaContent.URL
+	if image == "" && strings.HasPrefix(item.Enclosure.Type, "image/") {
+		image = item.Enclosure.URL
+	}
+
+	author := item.Creator
+	if author == "" {
+		author = item.Author
+	}
+
+	published := item.PubDate
+	if published == "" {
+		published = item.DCDate
+	}
+
+	description := stripHTML(item.Description)
+	content := stripHTML(item.ContentEncoded)
+	if content == "" {
+		content = description
+	}
+
+	return NewsArticle{
+		Title:       stripHTML(item.Title),
+		Description: description,
+		Content:     content,
+		Source:      source,
+		URL:         link,
+		PublishedAt: parseFeedTime(published),
+		Author:      strings.TrimSpace(author),
+		ImageURL:    image,
+		Category:    firstNonEmpty(item.Categories, feed.Category),
+		Symbols:     append([]string(nil), feed.Symbols...),
+	}
+}
+
+type atomEntry struct {
+	Title string `xml:"title"`
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	Summary   string `xml:"summary"`
+	Content   string `xml:"content"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+func (entry atomEntry) toArticle(source string, feed FeedConfig) NewsArticle {
+	var link, image string
+	for _, l := range entry.Links {
+		switch {
+		case (l.Rel == "" || l.Rel == "alternate") && link == "":
+			link = l.Href
+		case l.Rel == "enclosure" && strings.HasPrefix(l.Type, "image/") && image == "":
+			image = l.Href
+		}
+	}
+
+	published := entry.Published
+	if published == "" {
+		published = entry.Updated
+	}
+
+	var authors []string
+	for _, a := range entry.Authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+
+	var categories []string
+	for _, c := range entry.Categories {
+		categories = append(categories, c.Term)
+	}
+
+	description := stripHTML(entry.Summary)
+	content := stripHTML(entry.Content)
+	if description == "" {
+		description = content
+	}
+	if content == "" {
+		content = description
+	}
+
+	return NewsArticle{
+		Title:       stripHTML(entry.Title),
+		Description: description,
+		Content:     content,
+		Source:      source,
+		URL:         strings.TrimSpace(link),
+		PublishedAt: parseFeedTime(published),
+		Author:      strings.Join(authors, ", "),
+		ImageURL:    image,
+		Category:    firstNonEmpty(categories, feed.Category),
+		Symbols:     append([]string(nil), feed.Symbols...),
+	}
+}
+
+func feedSource(feed FeedConfig, title string) string {
+	if feed.Name != "" {
+		return feed.Name
+	}
+	if title = strings.TrimSpace(title); title != "" {
+		return title
+	}
+	return "RSS"
+}
+
+// Date layouts seen in the wild: RFC 822/1123 variants for RSS, RFC 3339 for Atom
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseFeedTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+var (
+	htmlTagPattern    = regexp.MustCompile(`(?s)<[^>]*>`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// stripHTML turns an HTML fragment from a feed into plain text
+func stripHTML(s string) string {
+	s = htmlTagPattern.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(s, " "))
+}
+
+func firstNonEmpty(values []string, fallback string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return fallback
+}
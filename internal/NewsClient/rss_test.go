@@ -0,0 +1,244 @@
+package NewsClient
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
+)
+
+func parseFixture(t *testing.T, name string, feed FeedConfig) []NewsArticle {
+	t.Helper()
+	file, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	articles, err := ParseFeed(file, feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return articles
+}
+
+func TestParseFeedRSS(t *testing.T) {
+	feed := FeedConfig{Name: "Example Corp IR", URL: "rss.xml", Symbols: []string{"EXMP"}, Category: "press-release"}
+	articles := parseFixture(t, "rss.xml", feed)
+	if len(articles) != 2 {
+		t.Fatalf("got %d articles, want 2 (entries without a title dropped)", len(articles))
+	}
+
+	first := articles[0]
+	checks := []struct{ field, got, want string }{
+		{"Title", first.Title, "Example Corp Reports Record Fourth Quarter Results"},
+		{"URL", first.URL, "https://investor.example.com/news/q4-results"},
+		{"Description", first.Description, "Revenue grew 18% year over year & margins expanded."},
+		{"Source", first.Source, "Example Corp IR"},
+		{"Author", first.Author, "Investor Relations"},
+		{"Category", first.Category, "Earnings"},
+		{"ImageURL", first.ImageURL, "https://investor.example.com/img/q4.jpg"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("first item %s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	if !strings.Contains(first.Content, "raised its full-year guidance") {
+		t.Errorf("first item Content = %q, want content:encoded text", first.Content)
+	}
+	if want := time.Date(2025, 1, 30, 21, 5, 0, 0, time.UTC); !first.PublishedAt.Equal(want) {
+		t.Errorf("first item PublishedAt = %v, want %v", first.PublishedAt, want)
+	}
+	if !slices.Equal(first.Symbols, []string{"EXMP"}) {
+		t.Errorf("first item Symbols = %v, want the feed's symbols", first.Symbols)
+	}
+
+	second := articles[1]
+	checks = []struct{ field, got, want string }{
+		{"URL", second.URL, "https://investor.example.com/news/buyback"}, // from the guid
+		{"Content", second.Content, second.Description},
+		{"Category", second.Category, "press-release"}, // the feed's fallback
+		{"ImageURL", second.ImageURL, "https://investor.example.com/img/buyback.png"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("second item %s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	if want := time.Date(2025, 2, 3, 13, 30, 0, 0, time.UTC); !second.PublishedAt.Equal(want) {
+		t.Errorf("second item PublishedAt = %v, want %v", second.PublishedAt, want)
+	}
+
+	// Without a name the channel title is the source
+	if got := parseFixture(t, "rss.xml", FeedConfig{URL: "rss.xml"})[0].Source; got != "Example Investor Relations" {
+		t.Errorf("unnamed feed Source = %q, want the channel title", got)
+	}
+}
+
+func TestParseFeedAtom(t *testing.T) {
+	articles := parseFixture(t, "atom.xml", FeedConfig{URL: "atom.xml", Category: "blog"})
+	if len(articles) != 2 {
+		t.Fatalf("got %d entries, want 2", len(articles))
+	}
+
+	first := articles[0]
+	checks := []struct{ field, got, want string }{
+		{"Title", first.Title, "Rates on hold as inflation cools"},
+		{"URL", first.URL, "https://blog.example.org/2025/02/rates-on-hold"},
+		{"Description", first.Description, "Bank stocks such as $JPM rallied after the decision."},
+		{"Source", first.Source, "Macro Notes"},
+		{"Author", first.Author, "Jane Analyst"},
+		{"Category", first.Category, "macro"},
+		{"ImageURL", first.ImageURL, "https://blog.example.org/img/rates.jpg"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("first entry %s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	if want := time.Date(2025, 2, 4, 8, 30, 0, 0, time.UTC); !first.PublishedAt.Equal(want) {
+		t.Errorf("first entry PublishedAt = %v, want %v", first.PublishedAt, want)
+	}
+
+	second := articles[1]
+	checks = []struct{ field, got, want string }{
+		{"URL", second.URL, "https://blog.example.org/2025/02/chipmakers"},
+		{"Description", second.Description, "NVIDIA and AMD led the semiconductor index higher."}, // from the content
+		{"Category", second.Category, "blog"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("second entry %s = %q, want %q", c.field, c.got, c.want)
+		}
+	}
+	// Falls back to <updated> without <published>
+	if want := time.Date(2025, 2, 3, 16, 45, 0, 0, time.UTC); !second.PublishedAt.Equal(want) {
+		t.Errorf("second entry PublishedAt = %v, want %v", second.PublishedAt, want)
+	}
+}
+
+func TestParseFeedRejectsOtherXML(t *testing.T) {
+	if _, err := ParseFeed(strings.NewReader("<html><body/></html>"), FeedConfig{URL: "page.html"}); err == nil {
+		t.Error("ParseFeed accepted an HTML document")
+	}
+}
+
+func TestParseFeedTime(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"Thu, 30 Jan 2025 21:05:00 +0000", time.Date(2025, 1, 30, 21, 5, 0, 0, time.UTC)},
+		{"Mon, 3 Feb 2025 13:30:00 GMT", time.Date(2025, 2, 3, 13, 30, 0, 0, time.UTC)},
+		{"Mon, 3 Feb 2025 13:30:00 -0500", time.Date(2025, 2, 3, 18, 30, 0, 0, time.UTC)},
+		{"3 Feb 2025 13:30:00 +0100", time.Date(2025, 2, 3, 12, 30, 0, 0, time.UTC)},
+		{"2025-02-04T08:30:00Z", time.Date(2025, 2, 4, 8, 30, 0, 0, time.UTC)},
+		{"2025-02-03T17:45:00+01:00", time.Date(2025, 2, 3, 16, 45, 0, 0, time.UTC)},
+		{"2025-02-04T08:30:00", time.Date(2025, 2, 4, 8, 30, 0, 0, time.UTC)},
+		{"  2025-02-04 ", time.Date(2025, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"", time.Time{}},
+		{"yesterday", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseFeedTime(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parseFeedTime(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFeedConfig(t *testing.T) {
+	feeds, err := LoadFeedConfig(filepath.Join("testdata", "feeds.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(feeds) != 2 {
+		t.Fatalf("got %d feeds, want 2", len(feeds))
+	}
+	// Relative paths are resolved against the config file's directory
+	for i, want := range []string{filepath.Join("testdata", "rss.xml"), filepath.Join("testdata", "atom.xml")} {
+		if feeds[i].URL != want {
+			t.Errorf("feed %d URL = %q, want %q", i, feeds[i].URL, want)
+		}
+	}
+	articles, err := fetchFeed(context.Background(), feeds[0], 10)
+	if err != nil {
+		t.Fatalf("fetching the resolved path: %v", err)
+	}
+	if len(articles) != 2 {
+		t.Errorf("got %d articles from %s, want 2", len(articles), feeds[0].URL)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "feeds.json")
+	absolute := filepath.Join(dir, "elsewhere", "ir.xml")
+	config := `[
+		{"url": "https://investor.example.com/rss", "symbols": ["$exmp.us"]},
+		{"url": "file:///var/feeds/ir.xml"},
+		{"url": "` + filepath.ToSlash(absolute) + `"},
+		{"url": "nested/blog.xml"}
+	]`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	feeds, err = LoadFeedConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantURLs := []string{
+		"https://investor.example.com/rss",
+		"file:///var/feeds/ir.xml",
+		filepath.ToSlash(absolute),
+		filepath.Join(dir, "nested", "blog.xml"),
+	}
+	for i, want := range wantURLs {
+		if feeds[i].URL != want {
+			t.Errorf("feed %d URL = %q, want %q", i, feeds[i].URL, want)
+		}
+	}
+	if !slices.Equal(feeds[0].Symbols, []string{"EXMP"}) {
+		t.Errorf("symbols = %v, want normalized [EXMP]", feeds[0].Symbols)
+	}
+	if !feeds[0].mentions("exmp") {
+		t.Error("feed mapped to EXMP does not mention exmp")
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name": "no url"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFeedConfig(path); err == nil {
+		t.Error("LoadFeedConfig accepted a feed without a url")
+	}
+	if _, err := LoadFeedConfig(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadFeedConfig accepted a missing file")
+	}
+}
+
+func TestGetFeedNewsBySymbol(t *testing.T) {
+	feeds, err := LoadFeedConfig(filepath.Join("testdata", "feeds.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := &NewsClient{feeds: feeds, linker: SymbolLinker.Default}
+
+	articles := nc.GetFeedNewsBySymbol(context.Background(), "exmp", 10)
+	if len(articles) != 2 {
+		t.Fatalf("got %d articles for EXMP, want the 2 from its IR feed", len(articles))
+	}
+	for _, a := range articles {
+		if a.Source != "Example Corp IR" || !slices.Contains(a.Symbols, "EXMP") {
+			t.Errorf("article %q from %q with symbols %v, want the EXMP IR feed", a.Title, a.Source, a.Symbols)
+		}
+	}
+	if got := nc.GetFeedNewsBySymbol(context.Background(), "EXMP", 1); len(got) != 1 {
+		t.Errorf("got %d articles with limit 1", len(got))
+	}
+	if got := nc.GetFeedNewsBySymbol(context.Background(), "AAPL", 10); got != nil {
+		t.Errorf("got %d articles for a ticker without feeds", len(got))
+	}
+}
@@ -0,0 +1,264 @@
+// Package SymbolLinker tags news text with the ticker symbols it mentions.
+// It combines symbols supplied by the news provider with a dictionary of
+// company names and tickers (normally built from company_profiles).
+package SymbolLinker
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Company is a single dictionary entry
+type Company struct {
+	Symbol string
+	Name   string
+}
+
+// Linker matches tickers and company names in free text. It is safe for
+// concurrent use; entries can be added while lookups are in flight.
+type Linker struct {
+	mu           sync.RWMutex
+	symbols      map[string]struct{}
+	names        map[string]string // normalised company name -> symbol
+	maxNameWords int
+}
+
+// Default is the process-wide linker used by the news clients
+var Default = New(nil)
+
+var (
+	// $AAPL
+	cashtagPattern = regexp.MustCompile(`\$([A-Z]{1,5}(?:\.[A-Z]{1,2})?)\b`)
+	// (NASDAQ: AAPL), NYSE:IBM
+	exchangePattern = regexp.MustCompile(`\b(?:NASDAQ|NYSE|AMEX|NYSEARCA|OTC|TSX|LSE)\s*:\s*([A-Z]{1,5}(?:\.[A-Z]{1,2})?)\b`)
+)
+
+// uppercase words that collide with real tickers but are almost always prose
+var tickerStopwords = map[string]struct{}{
+	"A": {}, "I": {}, "AI": {}, "ALL": {}, "ARE": {}, "AT": {}, "BE": {}, "CEO": {},
+	"CFO": {}, "EPS": {}, "ETF": {}, "EU": {}, "FOR": {}, "GDP": {}, "IPO": {},
+	"IT": {}, "NEW": {}, "NOW": {}, "ON": {}, "ONE": {}, "OR": {}, "SEC": {},
+	"SO": {}, "UK": {}, "US": {}, "USA": {}, "YOU": {},
+}
+
+// corporate suffixes stripped from the end of company names
+var nameSuffixes = map[string]struct{}{
+	"inc": {}, "incorporated": {}, "corp": {}, "corporation": {}, "co": {},
+	"company": {}, "ltd": {}, "limited": {}, "plc": {}, "sa": {}, "ag": {},
+	"nv": {}, "se": {}, "llc": {}, "lp": {}, "holdings": {}, "holding": {},
+	"group": {},
+}
+
+// New builds a linker from the given dictionary entries
+func New(companies []Company) *Linker {
+	l := &Linker{
+		symbols: make(map[string]struct{}),
+		names:   make(map[string]string),
+	}
+	for _, c := range companies {
+		l.add(c)
+	}
+	return l
+}
+
+// Load replaces the dictionary with the given entries
+func (l *Linker) Load(companies []Company) {
+	fresh := New(companies)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.symbols = fresh.symbols
+	l.names = fresh.names
+	l.maxNameWords = fresh.maxNameWords
+}
+
+// Add registers a single company in the dictionary
+func (l *Linker) Add(c Company) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(c)
+}
+
+func (l *Linker) add(c Company) {
+	symbol := NormalizeSymbol(c.Symbol)
+	if symbol == "" {
+		return
+	}
+	l.symbols[symbol] = struct{}{}
+
+	words := normalizeName(c.Name)
+	if len(words) == 0 {
+		return
+	}
+	l.names[strings.Join(words, " ")] = symbol
+	if len(words) > l.maxNameWords {
+		l.maxNameWords = len(words)
+	}
+}
+
+// Size returns the number of known symbols
+func (l *Linker) Size() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.symbols)
+}
+
+// Link returns the tickers an article refers to. Provider-supplied symbols
+// come first, followed by tickers found in the text in order of appearance.
+func (l *Linker) Link(text string, providerSymbols ...string) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	add := func(symbol string) {
+		symbol = NormalizeSymbol(symbol)
+		if symbol == "" {
+			return
+		}
+		if _, ok := seen[symbol]; ok {
+			return
+		}
+		seen[symbol] = struct{}{}
+		out = append(out, symbol)
+	}
+
+	for _, s := range providerSymbols {
+		add(s)
+	}
+
+	// Explicit ticker notation is trusted even for unknown symbols
+	for _, m := range cashtagPattern.FindAllStringSubmatch(text, -1) {
+		add(m[1])
+	}
+	for _, m := range exchangePattern.FindAllStringSubmatch(text, -1) {
+		add(m[1])
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '&' || r == '.')
+	})
+	lowered := make([]string, len(words))
+	for i, w := range words {
+		words[i] = strings.Trim(w, ".")
+		lowered[i] = strings.ToLower(words[i])
+	}
+
+	for i, w := range words {
+		// Bare tickers must be known and written in caps
+		if len(w) >= 2 && w == strings.ToUpper(w) {
+			if _, stop := tickerStopwords[w]; !stop {
+				if _, known := l.symbols[w]; known {
+					add(w)
+				}
+			}
+		}
+
+		// Company names, longest match first
+		for n := min(l.maxNameWords, len(words)-i); n >= 1; n-- {
+			symbol, ok := l.names[strings.Join(lowered[i:i+n], " ")]
+			if !ok {
+				continue
+			}
+			// Single-word names ("Target", "Apple") only count when capitalised
+			if n == 1 && !startsUpper(w) {
+				continue
+			}
+			add(symbol)
+			break
+		}
+	}
+
+	return out
+}
+
+// NormalizeSymbol uppercases a ticker and drops the EODHD ".US" suffix
+func NormalizeSymbol(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	symbol = strings.TrimPrefix(symbol, "$")
+	return strings.TrimSuffix(symbol, ".US")
+}
+
+// SplitSymbols parses a comma-separated provider symbol list
+func SplitSymbols(list string) []string {
+	var out []string
+	for _, s := range strings.Split(list, ",") {
+		if s = NormalizeSymbol(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// WithSymbol returns the tickers a piece of sentiment is attributed to: the
+// requested symbol first, followed by any other linked tickers.
+func WithSymbol(symbol string, linked []string) []string {
+	tickers := []string{NormalizeSymbol(symbol)}
+	for _, t := range linked {
+		if t != tickers[0] {
+			tickers = append(tickers, t)
+		}
+	}
+	return tickers
+}
+
+// SymbolList accepts provider symbols either as a JSON array or as a
+// comma-joined string ("AAPL.US,MSFT.US") and normalises each entry.
+type SymbolList []string
+
+func (sl *SymbolList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*sl = nil
+		for _, s := range list {
+			*sl = append(*sl, SplitSymbols(s)...)
+		}
+		return nil
+	}
+
+	var joined string
+	if err := json.Unmarshal(data, &joined); err != nil {
+		return err
+	}
+	*sl = SplitSymbols(joined)
+	return nil
+}
+
+// normalizeName lowercases a company name and strips share-class and
+// corporate suffixes: "Alphabet Inc. Class A" -> ["alphabet"]
+func normalizeName(name string) []string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '&')
+	})
+	for len(words) > 0 {
+		last := words[len(words)-1]
+		if len(words) >= 2 && words[len(words)-2] == "class" && len(last) == 1 {
+			words = words[:len(words)-2]
+			continue
+		}
+		if _, ok := nameSuffixes[last]; ok {
+			words = words[:len(words)-1]
+			continue
+		}
+		break
+	}
+	if len(words) > 0 && words[0] == "the" {
+		words = words[1:]
+	}
+
+	// Very short names ("GE", "3M") match too much prose
+	if len(words) == 1 && len(words[0]) < 3 {
+		return nil
+	}
+	return words
+}
+
+func startsUpper(s string) bool {
+	for _, r := range s {
+		return unicode.IsUpper(r)
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+// Package bulk scores large lists of texts as background jobs. An upload
+// is parsed into items, submitted as a job (see internal/jobs) and split
+// into chunks that are scored on a worker pool through the inference cache.
+// The results are stored with the job for download as NDJSON or CSV.
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/MadebyDaris/dogonomics/internal/jobs"
+	"github.com/MadebyDaris/dogonomics/internal/sentimentcache"
+	"github.com/MadebyDaris/dogonomics/internal/workerpool"
+	"github.com/MadebyDaris/dogonomics/sentAnalysis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Kind is the job kind of bulk sentiment jobs
+const Kind = "sentiment_batch"
+
+// Config controls bulk scoring
+type Config struct {
+	// Workers is how many chunks of one job are scored at once
+	Workers int
+	// ChunkSize is how many texts go to the model per call
+	ChunkSize int
+	// MaxItems caps the texts in one upload
+	MaxItems int
+	// MaxUploadBytes caps the size of one upload
+	MaxUploadBytes int64
+}
+
+// LoadConfigFromEnv loads bulk configuration from environment variables
+func LoadConfigFromEnv() *Config {
+	cfg := &Config{
+		Workers:        2,
+		ChunkSize:      32,
+		MaxItems:       10000,
+		MaxUploadBytes: 20 << 20,
+	}
+	if n, err := strconv.Atoi(os.Getenv("BULK_WORKERS")); err == nil && n > 0 {
+		cfg.Workers = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BULK_CHUNK_SIZE")); err == nil && n > 0 {
+		cfg.ChunkSize = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BULK_MAX_ITEMS")); err == nil && n > 0 {
+		cfg.MaxItems = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("BULK_MAX_UPLOAD_MB")); err == nil && n > 0 {
+		cfg.MaxUploadBytes = int64(n) << 20
+	}
+	return cfg
+}
+
+var itemsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "bulk_items_total",
+		Help: "Texts processed by bulk sentiment jobs by result (scored, failed)",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(itemsTotal)
+}
+
+var (
+	mutex  sync.RWMutex
+	config *Config
+)
+
+// Init sets the configuration used by Submit and Limits
+func Init(cfg *Config) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	config = cfg
+}
+
+func current() *Config {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if config == nil {
+		return LoadConfigFromEnv()
+	}
+	return config
+}
+
+// Limits returns the upload limits
+func Limits() (maxItems int, maxUploadBytes int64) {
+	cfg := current()
+	return cfg.MaxItems, cfg.MaxUploadBytes
+}
+
+// Result is the sentiment of one uploaded text, or the error that
+// prevented scoring it
+type Result struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+	*BertInference.BERTSentiment
+	Error string `json:"error,omitempty"`
+}
+
+// Params describes a bulk job
+type Params struct {
+	Model  string `json:"model,omitempty"`
+	Format string `json:"format"`
+	Items  int    `json:"items"`
+}
+
+// Submit queues items to be scored with the named model ("" for the
+// default) as a background job
+func Submit(items []Item, model, format string) (jobs.Status, error) {
+	cfg := current()
+	params := Params{Model: model, Format: format, Items: len(items)}
+	return jobs.Submit(Kind, params, func(ctx context.Context, progress *jobs.Progress) (any, error) {
+		return Score(ctx, cfg, items, model, progress)
+	})
+}
+
+// Score scores items chunk by chunk and returns their results in upload
+// order. A model error fails every item in its chunk but not the others.
+// When ctx ends early, the results so far are returned with its error; when
+// nothing could be scored, the results are returned with the first error.
+func Score(ctx context.Context, cfg *Config, items []Item, model string, progress *jobs.Progress) ([]Result, error) {
+	progress.SetTotal(len(items))
+
+	results := make([]Result, len(items))
+	var errs []error
+	var errMutex sync.Mutex
+
+	var tasks []workerpool.Task
+	for start := 0; start < len(items); start += cfg.ChunkSize {
+		end := min(start+cfg.ChunkSize, len(items))
+		tasks = append(tasks, func(ctx context.Context) error {
+			err := scoreChunk(ctx, items[start:end], model, results[start:end])
+			if err != nil {
+				errMutex.Lock()
+				errs = append(errs, err)
+				errMutex.Unlock()
+				itemsTotal.WithLabelValues("failed").Add(float64(end - start))
+				progress.Add(end-start, end-start)
+				return err
+			}
+			itemsTotal.WithLabelValues("scored").Add(float64(end - start))
+			progress.Add(end-start, 0)
+			return nil
+		})
+	}
+	workerpool.Run(ctx, cfg.Workers, tasks)
+
+	// Items in chunks that never ran have neither a sentiment nor an error
+	reached := results[:0:0]
+	failed := 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+		if r.BERTSentiment != nil || r.Error != "" {
+			reached = append(reached, r)
+		}
+	}
+	if ctx.Err() != nil {
+		return reached, ctx.Err()
+	}
+	if failed == len(items) && len(errs) > 0 {
+		return reached, errs[0]
+	}
+	return reached, nil
+}
+
+// scoreChunk scores one chunk into results
+func scoreChunk(ctx context.Context, items []Item, model string, results []Result) error {
+	docs := make([]BertInference.Document, len(items))
+	for i, item := range items {
+		docs[i] = sentAnalysis.NewsDocument(item.Title, item.Text)
+	}
+
+	scored, err := sentimentcache.RunDocuments(ctx, model, docs)
+	for i, item := range items {
+		results[i] = Result{ID: item.ID, Title: item.Title, Text: item.Text}
+		if err != nil {
+			results[i].Error = err.Error()
+		} else {
+			results[i].BERTSentiment = &scored[i].BERTSentiment
+		}
+	}
+	return err
+}
+
+// DecodeResults decodes the stored result of a bulk job
+func DecodeResults(data json.RawMessage) ([]Result, error) {
+	var results []Result
+	if len(data) == 0 {
+		return results, nil
+	}
+	err := json.Unmarshal(data, &results)
+	return results, err
+}
@@ -0,0 +1,52 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// csvHeader lists the columns of a CSV download
+var csvHeader = []string{"id", "title", "text", "label", "confidence", "score", "negative", "neutral", "positive", "model", "engine", "error"}
+
+// WriteNDJSON writes one JSON result per line
+func WriteNDJSON(w io.Writer, results []Result) error {
+	encoder := json.NewEncoder(w)
+	for _, r := range results {
+		if err := encoder.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteCSV writes the results as CSV with a header row. Sentiment columns
+// are empty for texts that failed.
+func WriteCSV(w io.Writer, results []Result) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := make([]string, 0, len(csvHeader))
+		record = append(record, r.ID, r.Title, r.Text)
+		if s := r.BERTSentiment; s != nil {
+			record = append(record, s.Label, formatFloat(s.Confidence), formatFloat(s.Score),
+				formatFloat(s.Probabilities.Negative), formatFloat(s.Probabilities.Neutral), formatFloat(s.Probabilities.Positive),
+				s.Model, s.Engine)
+		} else {
+			record = append(record, "", "", "", "", "", "", "", "")
+		}
+		record = append(record, r.Error)
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 6, 64)
+}
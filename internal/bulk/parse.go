@@ -0,0 +1,232 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Upload formats
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+)
+
+// ErrTooManyItems is returned when an upload has more than the allowed items
+var ErrTooManyItems = errors.New("too many items")
+
+// Item is one text to score. ID defaults to the 1-based position in the
+// upload.
+type Item struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+}
+
+// DetectFormat picks the upload format from an explicit format name, a
+// file extension or a Content-Type, in that order. It returns "" when none
+// of them say.
+func DetectFormat(format, contentType, filename string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatJSON:
+		return FormatJSON
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON
+	case FormatCSV:
+		return FormatCSV
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		return FormatJSON
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	case ".csv":
+		return FormatCSV
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/json":
+		return FormatJSON
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return FormatNDJSON
+	case "text/csv", "application/csv":
+		return FormatCSV
+	}
+	return ""
+}
+
+// Parse reads the items of an upload. JSON is an array of strings or of
+// objects with a "text" field; NDJSON is one string or object per line;
+// CSV needs a header with a text (or sentence) column and may have id and
+// title columns. Blank texts are skipped. More than maxItems items (when
+// positive) is an error.
+func Parse(r io.Reader, format string, maxItems int) ([]Item, error) {
+	var items []Item
+	var err error
+	switch format {
+	case FormatJSON:
+		items, err = parseJSON(r)
+	case FormatNDJSON:
+		items, err = parseNDJSON(r, maxItems)
+	case FormatCSV:
+		items, err = parseCSV(r, maxItems)
+	default:
+		return nil, fmt.Errorf("unsupported format %q (use json, ndjson or csv)", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if maxItems > 0 && len(items) > maxItems {
+		return nil, fmt.Errorf("%w: %d (max %d)", ErrTooManyItems, len(items), maxItems)
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no texts to score")
+	}
+	return items, nil
+}
+
+func parseJSON(r io.Reader) ([]Item, error) {
+	var raw []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON array: %v", err)
+	}
+	items := make([]Item, 0, len(raw))
+	for i, value := range raw {
+		item, err := decodeItem(value)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %v", i+1, err)
+		}
+		items = appendItem(items, item, i+1)
+	}
+	return items, nil
+}
+
+func parseNDJSON(r io.Reader, maxItems int) ([]Item, error) {
+	var items []Item
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		value := bytes.TrimSpace(scanner.Bytes())
+		if len(value) == 0 {
+			continue
+		}
+		item, err := decodeItem(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		items = appendItem(items, item, line)
+		if maxItems > 0 && len(items) > maxItems {
+			return nil, fmt.Errorf("%w (max %d)", ErrTooManyItems, maxItems)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("line %d: %v", line+1, err)
+	}
+	return items, nil
+}
+
+// decodeItem accepts a bare string or an object with text and optional id
+// and title. Numeric ids are kept as written.
+func decodeItem(value json.RawMessage) (Item, error) {
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		return Item{Text: text}, nil
+	}
+
+	var obj struct {
+		ID       json.RawMessage `json:"id"`
+		Title    string          `json:"title"`
+		Text     string          `json:"text"`
+		Sentence string          `json:"sentence"`
+	}
+	if err := json.Unmarshal(value, &obj); err != nil {
+		return Item{}, fmt.Errorf("expected a string or an object with a text field")
+	}
+	item := Item{Title: obj.Title, Text: obj.Text}
+	if item.Text == "" {
+		item.Text = obj.Sentence
+	}
+	if len(obj.ID) > 0 && string(obj.ID) != "null" {
+		var id string
+		if err := json.Unmarshal(obj.ID, &id); err != nil {
+			id = string(obj.ID)
+		}
+		item.ID = id
+	}
+	return item, nil
+}
+
+func parseCSV(r io.Reader, maxItems int) ([]Item, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %v", err)
+	}
+
+	textCol, idCol, titleCol := -1, -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))) {
+		case "text", "sentence":
+			if textCol < 0 {
+				textCol = i
+			}
+		case "id":
+			idCol = i
+		case "title", "headline":
+			titleCol = i
+		}
+	}
+	if textCol < 0 {
+		return nil, fmt.Errorf("CSV header needs a text or sentence column")
+	}
+
+	var items []Item
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV row %d: %v", row, err)
+		}
+		field := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return record[col]
+		}
+		items = appendItem(items, Item{ID: field(idCol), Title: field(titleCol), Text: field(textCol)}, row)
+		if maxItems > 0 && len(items) > maxItems {
+			return nil, fmt.Errorf("%w (max %d)", ErrTooManyItems, maxItems)
+		}
+	}
+	return items, nil
+}
+
+// appendItem trims the item, skips it when there is no text and fills in
+// its position as the ID
+func appendItem(items []Item, item Item, position int) []Item {
+	item.Text = strings.TrimSpace(item.Text)
+	item.Title = strings.TrimSpace(item.Title)
+	if item.Text == "" && item.Title == "" {
+		return items
+	}
+	if item.ID = strings.TrimSpace(item.ID); item.ID == "" {
+		item.ID = strconv.Itoa(position)
+	}
+	return append(items, item)
+}
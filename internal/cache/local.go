@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidationChannel carries the keys each replica writes or deletes so
+// the others drop their in-process copies
+const invalidationChannel = "cache:invalidate"
+
+// instanceID tells this process's own invalidations apart from other
+// replicas'
+var instanceID = uuid.NewString()
+
+var (
+	lookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cache_lookups_total",
+			Help: "Cache lookups by result (local_hit, redis_hit, miss)",
+		},
+		[]string{"result"},
+	)
+
+	localBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cache_local_bytes",
+			Help: "Bytes of values held in the in-process cache",
+		},
+	)
+
+	invalidationsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "cache_invalidations_received_total",
+			Help: "Keys dropped from the in-process cache on another replica's write",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lookupsTotal, localBytes, invalidationsTotal)
+}
+
+// localCache is the in-process tier: a byte-bounded LRU whose entries
+// expire with the Redis key they mirror, or after maxTTL at the latest
+type localCache struct {
+	mutex    sync.Mutex
+	maxBytes int
+	maxTTL   time.Duration
+	bytes    int
+	items    map[string]*list.Element
+	order    *list.List // front is most recently used
+	// tags indexes keys by tag for when there is no Redis
+	tags map[string]map[string]struct{}
+}
+
+type localEntry struct {
+	key     string
+	value   string
+	expires time.Time
+	tags    []string
+}
+
+// local is nil when the in-process tier is disabled
+var local *localCache
+
+func newLocalCache(maxBytes int, maxTTL time.Duration) *localCache {
+	return &localCache{
+		maxBytes: maxBytes,
+		maxTTL:   maxTTL,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+		tags:     make(map[string]map[string]struct{}),
+	}
+}
+
+func (c *localCache) get(key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*localEntry)
+	if time.Now().After(entry.expires) {
+		c.removeElement(el)
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true
+}
+
+// set stores value for ttl, capped at maxTTL, under tags. A ttl of zero or
+// less means the key does not expire and gets maxTTL. Values larger than
+// the whole cache are not kept.
+func (c *localCache) set(key, value string, ttl time.Duration, tags []string) {
+	if c == nil {
+		return
+	}
+	if ttl <= 0 || ttl > c.maxTTL {
+		ttl = c.maxTTL
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	if len(value) > c.maxBytes {
+		return
+	}
+	c.items[key] = c.order.PushFront(&localEntry{key: key, value: value, expires: time.Now().Add(ttl), tags: tags})
+	c.bytes += len(value)
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = make(map[string]struct{})
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	for c.bytes > c.maxBytes {
+		c.removeElement(c.order.Back())
+	}
+	localBytes.Set(float64(c.bytes))
+}
+
+// remove drops key and reports whether it was held
+func (c *localCache) remove(key string) bool {
+	if c == nil {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	el, ok := c.items[key]
+	if ok {
+		c.removeElement(el)
+		localBytes.Set(float64(c.bytes))
+	}
+	return ok
+}
+
+func (c *localCache) purge() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+	c.tags = make(map[string]map[string]struct{})
+	c.bytes = 0
+	localBytes.Set(0)
+}
+
+func (c *localCache) removeElement(el *list.Element) {
+	entry := el.Value.(*localEntry)
+	c.order.Remove(el)
+	delete(c.items, entry.key)
+	c.bytes -= len(entry.value)
+	for _, tag := range entry.tags {
+		delete(c.tags[tag], entry.key)
+		if len(c.tags[tag]) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+}
+
+// ttl returns how long key has left, or false when it is not held
+func (c *localCache) ttl(key string) (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return 0, false
+	}
+	left := time.Until(el.Value.(*localEntry).expires)
+	return left, left > 0
+}
+
+// keys returns the unexpired keys for which match is true, up to limit
+// when positive
+func (c *localCache) keys(match func(string) bool, limit int) []string {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	var keys []string
+	for key, el := range c.items {
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+		if now.Before(el.Value.(*localEntry).expires) && match(key) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// taggedKeys returns the unexpired keys under tag
+func (c *localCache) taggedKeys(tag string) []string {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	now := time.Now()
+	var keys []string
+	for key := range c.tags[tag] {
+		if now.Before(c.items[key].Value.(*localEntry).expires) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// invalidation is the message published on every write or delete
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// publishInvalidation queues the invalidation of keys on pipe
+func publishInvalidation(ctx context.Context, pipe redis.Pipeliner, keys ...string) {
+	data, err := json.Marshal(invalidation{Origin: instanceID, Keys: keys})
+	if err != nil {
+		return
+	}
+	pipe.Publish(ctx, invalidationChannel, data)
+}
+
+// subscribe drops in-process entries that other replicas write or delete
+// until the returned stop function is called
+func subscribe(client *redis.Client) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pubsub := client.Subscribe(ctx, invalidationChannel)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		listen(ctx, pubsub)
+	}()
+	return func() {
+		cancel()
+		pubsub.Close()
+		<-done
+	}
+}
+
+func listen(ctx context.Context, pubsub *redis.PubSub) {
+	subscribed := false
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Cache invalidation subscription error: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			// Invalidations published while the connection was down are
+			// lost, so start over after a reconnect
+			if subscribed && m.Kind == "subscribe" {
+				local.purge()
+			}
+			subscribed = true
+		case *redis.Message:
+			var inv invalidation
+			if err := json.Unmarshal([]byte(m.Payload), &inv); err != nil || inv.Origin == instanceID {
+				continue
+			}
+			for _, key := range inv.Keys {
+				local.remove(key)
+			}
+			invalidationsTotal.Add(float64(len(inv.Keys)))
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+)
+
+// LRU is a fixed-size, concurrency-safe in-process cache that evicts the
+// least recently used entry when full
+type LRU[K comparable, V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[K]*list.Element
+	order    *list.List // front is most recently used
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+// NewLRU creates an LRU holding at most capacity entries
+func NewLRU[K comparable, V any](capacity int) *LRU[K, V] {
+	return &LRU[K, V]{
+		capacity: max(capacity, 1),
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the value for key and marks it recently used
+func (c *LRU[K, V]) Get(key K) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Add stores value under key, evicting the least recently used entry if
+// the cache is full
+func (c *LRU[K, V]) Add(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+// Remove deletes key if present
+func (c *LRU[K, V]) Remove(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.Remove(el)
+		delete(c.items, key)
+	}
+}
+
+// Purge removes every entry
+func (c *LRU[K, V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+// Len returns the number of entries
+func (c *LRU[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
@@ -14,23 +14,40 @@ import (
 // Client is the package-level Redis client
 var Client *redis.Client
 
-// Config holds Redis connection configuration
+// stopSubscriber ends the invalidation subscription
+var stopSubscriber func()
+
+// Config holds Redis connection configuration and the size of the
+// in-process tier in front of it
 type Config struct {
 	Host     string
 	Port     string
 	Password string
 	DB       int
+	// LocalMaxBytes bounds the in-process tier; 0 disables it
+	LocalMaxBytes int
+	// LocalTTL caps how long a value is kept in process
+	LocalTTL time.Duration
 }
 
 // LoadConfigFromEnv loads Redis configuration from environment variables
 func LoadConfigFromEnv() *Config {
 	db, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
-	return &Config{
-		Host:     getEnv("REDIS_HOST", "localhost"),
-		Port:     getEnv("REDIS_PORT", "6379"),
-		Password: getEnv("REDIS_PASSWORD", ""),
-		DB:       db,
+	cfg := &Config{
+		Host:          getEnv("REDIS_HOST", "localhost"),
+		Port:          getEnv("REDIS_PORT", "6379"),
+		Password:      getEnv("REDIS_PASSWORD", ""),
+		DB:            db,
+		LocalMaxBytes: 64 << 20,
+		LocalTTL:      time.Minute,
+	}
+	if n, err := strconv.Atoi(os.Getenv("CACHE_LOCAL_MAX_MB")); err == nil && n >= 0 {
+		cfg.LocalMaxBytes = n << 20
+	}
+	if d, err := time.ParseDuration(os.Getenv("CACHE_LOCAL_TTL")); err == nil && d > 0 {
+		cfg.LocalTTL = d
 	}
+	return cfg
 }
 
 func getEnv(key, fallback string) string {
@@ -40,9 +57,15 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-// Connect initialises the Redis client and pings to verify connectivity.
-// Returns nil on success; the caller should log and continue without cache on error.
+// Connect sets up the in-process tier, then initialises the Redis client
+// and pings to verify connectivity. Returns nil on success; on error the
+// caller should log and continue with the in-process tier only.
 func Connect(cfg *Config) error {
+	if cfg.LocalMaxBytes > 0 {
+		local = newLocalCache(cfg.LocalMaxBytes, cfg.LocalTTL)
+		log.Printf("In-process cache enabled: %d MB, entries kept up to %s", cfg.LocalMaxBytes>>20, cfg.LocalTTL)
+	}
+
 	Client = redis.NewClient(&redis.Options{
 		Addr:         fmt.Sprintf("%s:%s", cfg.Host, cfg.Port),
 		Password:     cfg.Password,
@@ -63,11 +86,17 @@ func Connect(cfg *Config) error {
 	}
 
 	log.Printf("Connected to Redis at %s:%s (db %d)", cfg.Host, cfg.Port, cfg.DB)
+	if local != nil {
+		stopSubscriber = subscribe(Client)
+	}
 	return nil
 }
 
 // Close shuts down the Redis client connection
 func Close() {
+	if stopSubscriber != nil {
+		stopSubscriber()
+	}
 	if Client != nil {
 		if err := Client.Close(); err != nil {
 			log.Printf("Error closing Redis connection: %v", err)
@@ -77,32 +106,99 @@ func Close() {
 	}
 }
 
-// Get retrieves a cached value by key. Returns ("", nil) on cache miss.
+// Enabled reports whether values can be cached at all, in process or in
+// Redis
+func Enabled() bool {
+	return local != nil || Client != nil
+}
+
+// Get retrieves a cached value by key, from the in-process tier first and
+// then Redis. Values read from Redis are kept in process until their Redis
+// TTL runs out (at most CACHE_LOCAL_TTL). Returns ("", nil) on cache miss.
 func Get(ctx context.Context, key string) (string, error) {
+	if val, ok := local.get(key); ok {
+		lookupsTotal.WithLabelValues("local_hit").Inc()
+		return val, nil
+	}
 	if Client == nil {
+		lookupsTotal.WithLabelValues("miss").Inc()
 		return "", nil
 	}
-	val, err := Client.Get(ctx, key).Result()
+
+	pipe := Client.Pipeline()
+	get := pipe.Get(ctx, key)
+	ttl := pipe.PTTL(ctx, key)
+	_, err := pipe.Exec(ctx)
 	if err == redis.Nil {
+		lookupsTotal.WithLabelValues("miss").Inc()
 		return "", nil
 	}
-	return val, err
+	if err != nil {
+		return "", err
+	}
+	lookupsTotal.WithLabelValues("redis_hit").Inc()
+	local.set(key, get.Val(), ttl.Val(), nil)
+	return get.Val(), nil
 }
 
-// Set stores a value with the given TTL
+// Set stores a value with the given TTL in process and in Redis, and tells
+// other replicas to drop their in-process copy
 func Set(ctx context.Context, key string, value string, ttl time.Duration) error {
+	local.set(key, value, ttl, nil)
 	if Client == nil {
 		return nil
 	}
-	return Client.Set(ctx, key, value, ttl).Err()
+	pipe := Client.Pipeline()
+	pipe.Set(ctx, key, value, ttl)
+	publishInvalidation(ctx, pipe, key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetMany retrieves several keys in one round trip. Missing keys are
+// returned as "". GetMany and SetMany go straight to Redis: their callers
+// keep their own in-process cache of decoded values.
+func GetMany(ctx context.Context, keys []string) ([]string, error) {
+	values := make([]string, len(keys))
+	if Client == nil || len(keys) == 0 {
+		return values, nil
+	}
+	results, err := Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return values, err
+	}
+	for i, v := range results {
+		if s, ok := v.(string); ok {
+			values[i] = s
+		}
+	}
+	return values, nil
+}
+
+// SetMany stores several values with the same TTL in one pipeline
+func SetMany(ctx context.Context, values map[string]string, ttl time.Duration) error {
+	if Client == nil || len(values) == 0 {
+		return nil
+	}
+	pipe := Client.Pipeline()
+	for key, value := range values {
+		pipe.Set(ctx, key, value, ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-// Delete removes a cached entry
+// Delete removes a cached entry everywhere
 func Delete(ctx context.Context, key string) error {
+	local.remove(key)
 	if Client == nil {
 		return nil
 	}
-	return Client.Del(ctx, key).Err()
+	pipe := Client.Pipeline()
+	pipe.Del(ctx, key)
+	publishInvalidation(ctx, pipe, key)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
 // HealthCheck verifies the Redis connection is alive
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tagPrefix namespaces the Redis sets that record the keys under each tag
+const tagPrefix = "cachetag:"
+
+// deleteBatch is how many keys one DEL removes
+const deleteBatch = 500
+
+// SetTagged stores a value like Set and records key under each tag, so it
+// can be found and purged with the others carrying the tag. A tag's set
+// lives as long as its longest-lived key.
+func SetTagged(ctx context.Context, key, value string, ttl time.Duration, tags []string) error {
+	local.set(key, value, ttl, tags)
+	if Client == nil {
+		return nil
+	}
+	pipe := Client.Pipeline()
+	pipe.Set(ctx, key, value, ttl)
+	for _, tag := range tags {
+		pipe.SAdd(ctx, tagPrefix+tag, key)
+		pipe.ExpireNX(ctx, tagPrefix+tag, ttl)
+		pipe.ExpireGT(ctx, tagPrefix+tag, ttl)
+	}
+	publishInvalidation(ctx, pipe, key)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// TaggedKeys returns the live keys recorded under tag. Keys that expired
+// since they were tagged are dropped from the tag.
+func TaggedKeys(ctx context.Context, tag string) ([]string, error) {
+	if Client == nil {
+		return local.taggedKeys(tag), nil
+	}
+	members, err := Client.SMembers(ctx, tagPrefix+tag).Result()
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+
+	pipe := Client.Pipeline()
+	exists := make([]*redis.IntCmd, len(members))
+	for i, key := range members {
+		exists[i] = pipe.Exists(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+	var keys, expired []string
+	for i, key := range members {
+		if exists[i].Val() > 0 {
+			keys = append(keys, key)
+		} else {
+			expired = append(expired, key)
+		}
+	}
+	if len(expired) > 0 {
+		Client.SRem(ctx, tagPrefix+tag, expired)
+	}
+	return keys, nil
+}
+
+// Keys returns the keys matching a glob pattern (* and ? wildcards), up to
+// limit when positive
+func Keys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	if Client == nil {
+		return local.keys(func(key string) bool { return matchGlob(pattern, key) }, limit), nil
+	}
+	var keys []string
+	iter := Client.Scan(ctx, 0, pattern, deleteBatch).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if limit > 0 && len(keys) >= limit {
+			break
+		}
+	}
+	return keys, iter.Err()
+}
+
+// TTL returns how long key has left, or false when it does not exist.
+// Keys without an expiry report zero.
+func TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	if Client == nil {
+		ttl, ok := local.ttl(key)
+		return ttl, ok, nil
+	}
+	ttl, err := Client.PTTL(ctx, key).Result()
+	if err != nil || ttl == -2 {
+		return 0, false, err
+	}
+	return max(ttl, 0), true, nil
+}
+
+// DeleteMany removes keys everywhere and returns how many existed
+func DeleteMany(ctx context.Context, keys []string) (int, error) {
+	removed := 0
+	for _, key := range keys {
+		if local.remove(key) {
+			removed++
+		}
+	}
+	if Client == nil {
+		return removed, nil
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += deleteBatch {
+		batch := keys[start:min(start+deleteBatch, len(keys))]
+		pipe := Client.Pipeline()
+		del := pipe.Del(ctx, batch...)
+		publishInvalidation(ctx, pipe, batch...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return deleted, err
+		}
+		deleted += int(del.Val())
+	}
+	return deleted, nil
+}
+
+// PurgeTag removes every key under tag, and the tag, and returns how many
+// keys existed
+func PurgeTag(ctx context.Context, tag string) (int, error) {
+	keys, err := TaggedKeys(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := DeleteMany(ctx, keys)
+	if err != nil || Client == nil {
+		return deleted, err
+	}
+	return deleted, Client.Del(ctx, tagPrefix+tag).Err()
+}
+
+// matchGlob reports whether s matches pattern, where * matches any run of
+// characters (including /) and ? any single one. It stands in for Redis
+// MATCH when there is no Redis.
+func matchGlob(pattern, s string) bool {
+	p, t := []rune(pattern), []rune(s)
+	// star and mark remember the last * and where it started matching, to
+	// backtrack when the rest fails
+	star, mark := -1, 0
+	i, j := 0, 0
+	for j < len(t) {
+		switch {
+		case i < len(p) && p[i] == '*':
+			star, mark = i, j
+			i++
+		case i < len(p) && (p[i] == '?' || p[i] == t[j]):
+			i++
+			j++
+		case star >= 0:
+			i = star + 1
+			mark++
+			j = mark
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '*' {
+		i++
+	}
+	return i == len(p)
+}
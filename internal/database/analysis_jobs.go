@@ -0,0 +1,174 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// AnalysisJob is an asynchronous analysis request and, once finished, its
+// result
+type AnalysisJob struct {
+	ID           uuid.UUID
+	Kind         string
+	Status       string // queued | running | completed | failed | cancelled
+	Params       json.RawMessage
+	Total        int
+	Processed    int
+	Failed       int
+	Result       json.RawMessage
+	ErrorMessage *string
+	Owner        string // instance running the job
+	CreatedAt    time.Time
+	StartedAt    *time.Time
+	FinishedAt   *time.Time
+}
+
+// SaveAnalysisJob inserts a job or updates its state, counts and result.
+// Saving also refreshes the job's heartbeat.
+func SaveAnalysisJob(ctx context.Context, job *AnalysisJob) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
+
+	query := `
+		INSERT INTO analysis_jobs (
+			id, kind, status, params, total, processed, failed,
+			result, error_message, created_at, started_at, finished_at,
+			owner, heartbeat_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
+		ON CONFLICT (id) DO UPDATE SET
+			status = EXCLUDED.status,
+			total = EXCLUDED.total,
+			processed = EXCLUDED.processed,
+			failed = EXCLUDED.failed,
+			result = EXCLUDED.result,
+			error_message = EXCLUDED.error_message,
+			started_at = EXCLUDED.started_at,
+			finished_at = EXCLUDED.finished_at,
+			owner = EXCLUDED.owner,
+			heartbeat_at = EXCLUDED.heartbeat_at
+	`
+
+	_, err := DB.Exec(ctx, query,
+		job.ID,
+		job.Kind,
+		job.Status,
+		nullJSON(job.Params),
+		job.Total,
+		job.Processed,
+		job.Failed,
+		nullJSON(job.Result),
+		job.ErrorMessage,
+		job.CreatedAt,
+		job.StartedAt,
+		job.FinishedAt,
+		job.Owner,
+	)
+
+	return err
+}
+
+// GetAnalysisJob returns a job by ID, or nil when there is none
+func GetAnalysisJob(ctx context.Context, id uuid.UUID) (*AnalysisJob, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	query := `
+		SELECT id, kind, status, params, total, processed, failed,
+		       result, error_message, created_at, started_at, finished_at
+		FROM analysis_jobs
+		WHERE id = $1
+	`
+
+	var job AnalysisJob
+	var params, result []byte
+	err := DB.QueryRow(ctx, query, id).Scan(
+		&job.ID,
+		&job.Kind,
+		&job.Status,
+		&params,
+		&job.Total,
+		&job.Processed,
+		&job.Failed,
+		&result,
+		&job.ErrorMessage,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	job.Params, job.Result = params, result
+	return &job, nil
+}
+
+// HeartbeatAnalysisJobs records that owner is still alive for each of its
+// queued or running jobs
+func HeartbeatAnalysisJobs(ctx context.Context, owner string) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
+
+	_, err := DB.Exec(ctx, `
+		UPDATE analysis_jobs
+		SET heartbeat_at = NOW()
+		WHERE owner = $1 AND status IN ('queued', 'running')
+	`, owner)
+	return err
+}
+
+// AbandonAnalysisJobs marks queued or running jobs as failed when they
+// belong to owner, i.e. to an earlier process of this instance, or when
+// their heartbeat is older than staleBefore, and returns their IDs. An
+// empty owner abandons only stale jobs. Jobs from before heartbeats were
+// recorded count from their creation time.
+func AbandonAnalysisJobs(ctx context.Context, owner string, staleBefore time.Time) ([]uuid.UUID, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	rows, err := DB.Query(ctx, `
+		UPDATE analysis_jobs
+		SET status = 'failed', finished_at = NOW(),
+		    error_message = CASE WHEN owner = $1 THEN 'interrupted by shutdown' ELSE 'abandoned by its instance' END
+		WHERE status IN ('queued', 'running')
+		  AND (owner = $1 OR COALESCE(heartbeat_at, created_at) < $2)
+		RETURNING id
+	`, owner, staleBefore)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
+
+// DeleteAnalysisJobsBefore removes jobs that finished before the given time.
+// Returns the number deleted.
+func DeleteAnalysisJobsBefore(ctx context.Context, before time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrDatabaseNotConnected
+	}
+
+	tag, err := DB.Exec(ctx, `DELETE FROM analysis_jobs WHERE finished_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// nullJSON stores empty JSON as NULL
+func nullJSON(data json.RawMessage) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return data
+}
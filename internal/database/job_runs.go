@@ -0,0 +1,147 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobRun is one execution of a scheduled background job
+type JobRun struct {
+	ID             uuid.UUID  `json:"id"`
+	JobName        string     `json:"job_name"`
+	Trigger        string     `json:"trigger"` // schedule | manual
+	Status         string     `json:"status"`  // running | succeeded | partial | failed
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	ItemsProcessed int        `json:"items_processed"`
+	ItemsFailed    int        `json:"items_failed"`
+	ErrorMessage   *string    `json:"error_message,omitempty"`
+	Failures       []string   `json:"failures,omitempty"`
+}
+
+// StartJobRun records the start of a job run and returns its ID
+func StartJobRun(ctx context.Context, run *JobRun) (uuid.UUID, error) {
+	if DB == nil {
+		return uuid.Nil, ErrDatabaseNotConnected
+	}
+
+	query := `
+		INSERT INTO job_runs (job_name, trigger, status, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	var id uuid.UUID
+	err := DB.QueryRow(ctx, query, run.JobName, run.Trigger, run.Status, run.StartedAt).Scan(&id)
+	return id, err
+}
+
+// FinishJobRun stores the outcome of a job run started with StartJobRun
+func FinishJobRun(ctx context.Context, run *JobRun) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
+
+	var failures []byte
+	if len(run.Failures) > 0 {
+		var err error
+		if failures, err = json.Marshal(run.Failures); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE job_runs SET
+			status = $2,
+			finished_at = $3,
+			items_processed = $4,
+			items_failed = $5,
+			error_message = $6,
+			failures = $7
+		WHERE id = $1
+	`
+
+	_, err := DB.Exec(ctx, query,
+		run.ID,
+		run.Status,
+		run.FinishedAt,
+		run.ItemsProcessed,
+		run.ItemsFailed,
+		run.ErrorMessage,
+		failures,
+	)
+
+	return err
+}
+
+// AbandonRunningJobs marks runs left in the running state by a previous
+// process (crash or hard restart) as failed. Returns the number updated.
+func AbandonRunningJobs(ctx context.Context) (int64, error) {
+	if DB == nil {
+		return 0, ErrDatabaseNotConnected
+	}
+
+	tag, err := DB.Exec(ctx, `
+		UPDATE job_runs
+		SET status = 'failed', finished_at = NOW(), error_message = 'interrupted by shutdown'
+		WHERE status = 'running'
+	`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// GetJobRuns returns the most recent runs of a job, newest first
+func GetJobRuns(ctx context.Context, jobName string, limit int) ([]JobRun, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	query := `
+		SELECT id, job_name, trigger, status, started_at, finished_at,
+		       items_processed, items_failed, error_message, failures
+		FROM job_runs
+		WHERE job_name = $1
+		ORDER BY started_at DESC
+		LIMIT $2
+	`
+
+	rows, err := DB.Query(ctx, query, jobName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []JobRun
+	for rows.Next() {
+		var run JobRun
+		var failures []byte
+		err := rows.Scan(
+			&run.ID,
+			&run.JobName,
+			&run.Trigger,
+			&run.Status,
+			&run.StartedAt,
+			&run.FinishedAt,
+			&run.ItemsProcessed,
+			&run.ItemsFailed,
+			&run.ErrorMessage,
+			&failures,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(failures) > 0 {
+			if err := json.Unmarshal(failures, &run.Failures); err != nil {
+				return nil, err
+			}
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/MadebyDaris/dogonomics/internal/NewsClient"
+)
+
+// ModelAgreement compares FinBERT with a provider's own sentiment over a
+// period. Date is nil for the summary row covering the whole period.
+type ModelAgreement struct {
+	Date              *time.Time `json:"date,omitempty"`
+	Pairs             int64      `json:"pairs"`
+	AgreementRate     *float64   `json:"agreement_rate"`
+	AvgFinBERTScore   *float64   `json:"avg_finbert_score"`
+	AvgProviderScore  *float64   `json:"avg_provider_score"`
+	MeanAbsDifference *float64   `json:"mean_abs_difference"`
+	Correlation       *float64   `json:"correlation"`
+}
+
+// SaveArticleSentiment stores FinBERT's result for an article attributed to
+// one ticker, alongside the provider's own score for that ticker when the
+// article carries one (falling back to the provider's overall score).
+func SaveArticleSentiment(ctx context.Context, symbol string, article *NewsClient.NewsArticle, sentiment *BertInference.BERTSentiment) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
+
+	record := bertSentimentRecord(symbol, sentiment)
+
+	if ps := article.ProviderSentiment; ps != nil {
+		record.Provider = &ps.Provider
+		if ts := ps.ForTicker(symbol); ts != nil {
+			record.ProviderScore = &ts.Score
+			record.ProviderLabel = &ts.Label
+			record.ProviderRelevance = &ts.Relevance
+		} else {
+			record.ProviderScore = &ps.OverallScore
+			record.ProviderLabel = &ps.OverallLabel
+		}
+	}
+
+	return SaveSentimentAnalysis(ctx, record)
+}
+
+// GetModelAgreement returns daily FinBERT-vs-provider agreement for a symbol,
+// newest first, followed by a summary row for the whole period. Provider
+// scores are mapped to three classes using Alpha Vantage's ±0.15 thresholds.
+func GetModelAgreement(ctx context.Context, symbol string, days int) ([]ModelAgreement, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	query := `
+		SELECT
+			time_bucket('1 day', analyzed_at)::DATE AS day,
+			COUNT(*) AS pairs,
+			AVG(CASE WHEN bert_label = provider_class THEN 1.0 ELSE 0.0 END)::FLOAT8,
+			AVG(bert_score)::FLOAT8,
+			AVG(provider_score)::FLOAT8,
+			AVG(ABS(bert_score - provider_score))::FLOAT8,
+			CORR(bert_score, provider_score)
+		FROM (
+			SELECT analyzed_at, bert_label, bert_score, provider_score,
+			       CASE
+			           WHEN provider_score <= -0.15 THEN 'negative'
+			           WHEN provider_score >= 0.15 THEN 'positive'
+			           ELSE 'neutral'
+			       END AS provider_class
+			FROM sentiment_analysis
+			WHERE symbol = $1
+			  AND analyzed_at >= NOW() - make_interval(days => $2)
+			  AND bert_label IS NOT NULL
+			  AND provider_score IS NOT NULL
+		) paired
+		GROUP BY GROUPING SETS ((time_bucket('1 day', analyzed_at)::DATE), ())
+		ORDER BY day DESC NULLS LAST
+	`
+
+	rows, err := DB.Query(ctx, query, symbol, days)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ModelAgreement
+	for rows.Next() {
+		var ma ModelAgreement
+		err := rows.Scan(
+			&ma.Date,
+			&ma.Pairs,
+			&ma.AgreementRate,
+			&ma.AvgFinBERTScore,
+			&ma.AvgProviderScore,
+			&ma.MeanAbsDifference,
+			&ma.Correlation,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, ma)
+	}
+
+	return results, rows.Err()
+}
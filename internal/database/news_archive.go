@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/internal/NewsClient"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidCursor is returned when a search cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid search cursor")
+
+// ArchivedArticle is a news article stored in the news_items archive
+type ArchivedArticle struct {
+	ID     uuid.UUID `json:"id"`
+	Symbol string    `json:"symbol,omitempty"`
+	NewsClient.NewsArticle
+	FetchedAt time.Time `json:"fetched_at"`
+	Rank      float32   `json:"rank"`
+}
+
+// NewsSearchParams holds the filters for a full-text archive search
+type NewsSearchParams struct {
+	Query  string // websearch syntax: "exact phrase", OR, -exclude
+	Symbol string // matches the fetch symbol or any linked ticker
+	Source string // case-insensitive substring of the source name
+	From   *time.Time
+	To     *time.Time
+	Limit  int
+	Cursor string // opaque cursor returned by the previous page
+}
+
+// NewsSearchPage is one page of archive search results
+type NewsSearchPage struct {
+	Articles   []ArchivedArticle
+	NextCursor string
+}
+
+// ArchiveNewsArticles stores fetched articles in news_items, skipping any that
+// are already archived for the same symbol. Use an empty symbol for general
+// market news; linked tickers are kept in the symbols column either way.
+// Returns the articles newly inserted.
+func ArchiveNewsArticles(ctx context.Context, symbol string, articles []NewsClient.NewsArticle) ([]NewsClient.NewsArticle, error) {
+	if DB == nil {
+		return nil, nil // Silently skip if DB not configured
+	}
+
+	// Dedupe on link when the provider gives one, otherwise on the headline.
+	// The article is claimed in news_archive_keys, whose primary key makes
+	// concurrent archivers agree; the NOT EXISTS also skips articles stored
+	// by the sentiment paths, which don't claim keys.
+	query := `
+		WITH claimed AS (
+			INSERT INTO news_archive_keys (symbol, dedupe_key)
+			SELECT $1, $12
+			WHERE NOT EXISTS (
+				SELECT 1 FROM news_items
+				WHERE symbol = $1
+				  AND ((link <> '' AND link = $7) OR (coalesce(link, '') = '' AND title = $2))
+			)
+			ON CONFLICT DO NOTHING
+			RETURNING symbol
+		)
+		INSERT INTO news_items (
+			symbol, title, description, content, published_date,
+			source, link, author, image_url, category, symbols
+		)
+		SELECT $1, $2, $3, $4, $5::TIMESTAMPTZ, $6, $7, $8, $9, $10, $11::TEXT[]
+		FROM claimed
+	`
+
+	var inserted []NewsClient.NewsArticle
+	for _, article := range articles {
+		if strings.TrimSpace(article.Title) == "" {
+			continue
+		}
+
+		var publishedDate *time.Time
+		if !article.PublishedAt.IsZero() {
+			published := article.PublishedAt
+			publishedDate = &published
+		}
+
+		tag, err := DB.Exec(ctx, query,
+			strings.ToUpper(symbol),
+			article.Title,
+			nullableString(article.Description),
+			nullableString(article.Content),
+			publishedDate,
+			nullableString(article.Source),
+			article.URL,
+			nullableString(article.Author),
+			nullableString(article.ImageURL),
+			nullableString(article.Category),
+			article.Symbols,
+			archiveKey(article),
+		)
+		if err != nil {
+			return inserted, fmt.Errorf("failed to archive article %q: %w", article.Title, err)
+		}
+		if tag.RowsAffected() > 0 {
+			inserted = append(inserted, article)
+		}
+	}
+
+	return inserted, nil
+}
+
+// archiveKey identifies an article within a symbol's archive
+func archiveKey(article NewsClient.NewsArticle) string {
+	if article.URL != "" {
+		return article.URL
+	}
+	return "title:" + article.Title
+}
+
+// SearchNewsArchive runs a ranked full-text search over the news archive.
+// Results are ordered by relevance and paginated with an opaque cursor.
+func SearchNewsArchive(ctx context.Context, params NewsSearchParams) (*NewsSearchPage, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	limit := params.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	var cursorRank *float32
+	var cursorID *uuid.UUID
+	if params.Cursor != "" {
+		rank, id, err := decodeSearchCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursorRank = &rank
+		cursorID = &id
+	}
+
+	query := `
+		SELECT id, symbol, title, description, content, published_date,
+		       source, link, author, image_url, category, symbols, fetched_at, rank
+		FROM (
+			SELECT n.*, ts_rank_cd(n.search_vector, q.query) AS rank
+			FROM news_items n, websearch_to_tsquery('english', $1) AS q(query)
+			WHERE n.search_vector @@ q.query
+			  AND ($2 = '' OR n.symbol = $2 OR $2 = ANY(n.symbols))
+			  AND ($3 = '' OR n.source ILIKE '%' || $3 || '%')
+			  AND ($4::TIMESTAMPTZ IS NULL OR n.published_date >= $4)
+			  AND ($5::TIMESTAMPTZ IS NULL OR n.published_date < $5)
+		) ranked
+		WHERE ($6::REAL IS NULL OR (ranked.rank, ranked.id) < ($6::REAL, $7::UUID))
+		ORDER BY rank DESC, id DESC
+		LIMIT $8
+	`
+
+	// Fetch one extra row to know whether another page exists
+	rows, err := DB.Query(ctx, query,
+		params.Query,
+		strings.ToUpper(params.Symbol),
+		params.Source,
+		params.From,
+		params.To,
+		cursorRank,
+		cursorID,
+		limit+1,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &NewsSearchPage{}
+	for rows.Next() {
+		var (
+			a                                                 ArchivedArticle
+			description, content, source, link, author, image *string
+			category                                          *string
+			publishedDate                                     *time.Time
+		)
+		err := rows.Scan(
+			&a.ID,
+			&a.Symbol,
+			&a.Title,
+			&description,
+			&content,
+			&publishedDate,
+			&source,
+			&link,
+			&author,
+			&image,
+			&category,
+			&a.Symbols,
+			&a.FetchedAt,
+			&a.Rank,
+		)
+		if err != nil {
+			return nil, err
+		}
+		a.Description = derefString(description)
+		a.Content = derefString(content)
+		a.Source = derefString(source)
+		a.URL = derefString(link)
+		a.Author = derefString(author)
+		a.ImageURL = derefString(image)
+		a.Category = derefString(category)
+		if publishedDate != nil {
+			a.PublishedAt = *publishedDate
+		}
+		page.Articles = append(page.Articles, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Articles) > limit {
+		page.Articles = page.Articles[:limit]
+		last := page.Articles[limit-1]
+		page.NextCursor = encodeSearchCursor(last.Rank, last.ID)
+	}
+
+	return page, nil
+}
+
+// encodeSearchCursor packs the (rank, id) keyset position of the last row
+func encodeSearchCursor(rank float32, id uuid.UUID) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSearchCursor(cursor string) (float32, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, uuid.Nil, ErrInvalidCursor
+	}
+	rankStr, idStr, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return 0, uuid.Nil, ErrInvalidCursor
+	}
+	rank, err := strconv.ParseFloat(rankStr, 32)
+	if err != nil {
+		return 0, uuid.Nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return 0, uuid.Nil, ErrInvalidCursor
+	}
+	return float32(rank), id, nil
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -1,5 +1,11 @@
 -- Dogonomics Database Schema
 -- TimescaleDB (PostgreSQL 14+ with TimescaleDB extension)
+--
+-- Safe to run again on an existing database: every statement is idempotent.
+-- CREATE TABLE IF NOT EXISTS leaves existing tables alone, so columns added
+-- after a table was first released are also added with ALTER TABLE ... ADD
+-- COLUMN IF NOT EXISTS. Docker only runs this file on a fresh volume; after
+-- upgrading, apply it by hand (see DOCS.md, Upgrading the schema).
 
 -- Enable extensions
 CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
@@ -24,8 +30,8 @@ CREATE TABLE IF NOT EXISTS api_requests (
 
 SELECT create_hypertable('api_requests', 'timestamp', if_not_exists => TRUE);
 
-CREATE INDEX idx_api_requests_symbol ON api_requests(symbol, timestamp DESC) WHERE symbol IS NOT NULL;
-CREATE INDEX idx_api_requests_endpoint ON api_requests(endpoint, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_api_requests_symbol ON api_requests(symbol, timestamp DESC) WHERE symbol IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_api_requests_endpoint ON api_requests(endpoint, timestamp DESC);
 
 -- Automatic retention: drop chunks older than 30 days
 SELECT add_retention_policy('api_requests', INTERVAL '30 days', if_not_exists => TRUE);
@@ -51,7 +57,7 @@ CREATE TABLE IF NOT EXISTS stock_quotes (
 
 SELECT create_hypertable('stock_quotes', 'timestamp', if_not_exists => TRUE);
 
-CREATE INDEX idx_stock_quotes_symbol_timestamp ON stock_quotes(symbol, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_quotes_symbol_timestamp ON stock_quotes(symbol, timestamp DESC);
 
 -- ============================================================
 -- Regular table: Company Profiles (lookup, not time-series)
@@ -73,8 +79,8 @@ CREATE TABLE IF NOT EXISTS company_profiles (
     raw_data JSONB
 );
 
-CREATE INDEX idx_company_profiles_symbol ON company_profiles(symbol);
-CREATE INDEX idx_company_profiles_sector ON company_profiles(sector) WHERE sector IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_company_profiles_symbol ON company_profiles(symbol);
+CREATE INDEX IF NOT EXISTS idx_company_profiles_sector ON company_profiles(sector) WHERE sector IS NOT NULL;
 
 -- ============================================================
 -- Hypertable: News Items
@@ -90,12 +96,63 @@ CREATE TABLE IF NOT EXISTS news_items (
     link TEXT,
     fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     tags TEXT[],
+    symbols TEXT[],          -- every ticker the article mentions
+    description TEXT,
+    author TEXT,
+    image_url TEXT,
+    category VARCHAR(100),
+
+    -- Full-text search document: headline weighted above body
+    search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(content, '')), 'C')
+    ) STORED,
     UNIQUE(symbol, link, fetched_at)
 );
 
+-- Upgrade: article metadata and full-text search for the news archive
+ALTER TABLE news_items ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE news_items ADD COLUMN IF NOT EXISTS author TEXT;
+ALTER TABLE news_items ADD COLUMN IF NOT EXISTS image_url TEXT;
+ALTER TABLE news_items ADD COLUMN IF NOT EXISTS category VARCHAR(100);
+ALTER TABLE news_items ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(content, '')), 'C')
+) STORED;
+
+-- Upgrade: tickers linked from the article text
+ALTER TABLE news_items ADD COLUMN IF NOT EXISTS symbols TEXT[];
+
 SELECT create_hypertable('news_items', 'fetched_at', if_not_exists => TRUE);
 
-CREATE INDEX idx_news_items_symbol_date ON news_items(symbol, published_date DESC);
+CREATE INDEX IF NOT EXISTS idx_news_items_symbol_date ON news_items(symbol, published_date DESC);
+CREATE INDEX IF NOT EXISTS idx_news_items_link ON news_items(link, symbol);
+CREATE INDEX IF NOT EXISTS idx_news_items_search ON news_items USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_news_items_symbols ON news_items USING GIN (symbols);
+
+-- ============================================================
+-- Table: News Archive Keys
+-- One row per archived article and symbol: the link, or the headline
+-- for articles without one. Unique indexes on a hypertable must include
+-- its partition column (fetched_at), which would let the same link be
+-- archived again at a later time, so the archive claims each article
+-- here first (INSERT ... ON CONFLICT DO NOTHING) and concurrent fetches
+-- of the same article store it only once.
+-- ============================================================
+CREATE TABLE IF NOT EXISTS news_archive_keys (
+    symbol VARCHAR(20) NOT NULL,
+    dedupe_key TEXT NOT NULL,           -- link, or 'title:' || title when there is none
+    claimed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (symbol, dedupe_key)
+);
+
+-- Upgrade: claim the articles archived before this table existed
+INSERT INTO news_archive_keys (symbol, dedupe_key)
+SELECT DISTINCT symbol, CASE WHEN coalesce(link, '') <> '' THEN link ELSE 'title:' || title END
+FROM news_items
+ON CONFLICT DO NOTHING;
 
 -- ============================================================
 -- Hypertable: Sentiment Analysis Results
@@ -111,23 +168,73 @@ CREATE TABLE IF NOT EXISTS sentiment_analysis (
     bert_label VARCHAR(20),
     bert_confidence DECIMAL(5, 4),
     bert_score DECIMAL(5, 4),
-    
-    -- Basic Sentiment (if available)
-    polarity DECIMAL(5, 4),
+
+    -- BERT class probabilities and raw logits
     positive_score DECIMAL(5, 4),
     neutral_score DECIMAL(5, 4),
     negative_score DECIMAL(5, 4),
+    positive_logit REAL,
+    neutral_logit REAL,
+    negative_logit REAL,
+    
+    -- Basic Sentiment (if available)
+    polarity DECIMAL(5, 4),
     
     -- Model info
     model_version VARCHAR(50),
-    inference_time_ms INT
+    inference_time_ms INT,
+
+    -- Provider's own sentiment for the same article (e.g. Alpha Vantage NEWS_SENTIMENT)
+    provider VARCHAR(50),
+    provider_score DECIMAL(5, 4),
+    provider_label VARCHAR(30),
+    provider_relevance DECIMAL(5, 4)
 );
 
+-- Upgrade: raw logits next to the class probabilities
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS positive_logit REAL;
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS neutral_logit REAL;
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS negative_logit REAL;
+
+-- Upgrade: provider sentiment stored next to FinBERT's
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS provider VARCHAR(50);
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS provider_score DECIMAL(5, 4);
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS provider_label VARCHAR(30);
+ALTER TABLE sentiment_analysis ADD COLUMN IF NOT EXISTS provider_relevance DECIMAL(5, 4);
+
 SELECT create_hypertable('sentiment_analysis', 'analyzed_at', if_not_exists => TRUE);
 
-CREATE INDEX idx_sentiment_symbol_date ON sentiment_analysis(symbol, analyzed_at DESC);
-CREATE INDEX idx_sentiment_news_item ON sentiment_analysis(news_item_id, analyzed_at DESC);
-CREATE INDEX idx_sentiment_bert_label ON sentiment_analysis(bert_label, analyzed_at DESC) WHERE bert_label IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_sentiment_symbol_date ON sentiment_analysis(symbol, analyzed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sentiment_news_item ON sentiment_analysis(news_item_id, analyzed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sentiment_bert_label ON sentiment_analysis(bert_label, analyzed_at DESC) WHERE bert_label IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_sentiment_provider ON sentiment_analysis(symbol, analyzed_at DESC) WHERE provider IS NOT NULL;
+
+-- ============================================================
+-- Hypertable: Shadow Sentiment
+-- A candidate model's result next to the production result for the same
+-- article and ticker, for comparing models before promotion
+-- ============================================================
+CREATE TABLE IF NOT EXISTS shadow_sentiment (
+    id UUID NOT NULL DEFAULT uuid_generate_v4(),
+    symbol VARCHAR(20) NOT NULL,
+    analyzed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    link TEXT,
+
+    production_model VARCHAR(50) NOT NULL,
+    production_label VARCHAR(20) NOT NULL,
+    production_confidence DECIMAL(5, 4),
+    production_score DECIMAL(5, 4),
+
+    candidate_model VARCHAR(50) NOT NULL,
+    candidate_label VARCHAR(20) NOT NULL,
+    candidate_confidence DECIMAL(5, 4),
+    candidate_score DECIMAL(5, 4)
+);
+
+SELECT create_hypertable('shadow_sentiment', 'analyzed_at', if_not_exists => TRUE);
+
+CREATE INDEX IF NOT EXISTS idx_shadow_symbol_model ON shadow_sentiment(symbol, candidate_model, analyzed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shadow_link ON shadow_sentiment(candidate_model, link) WHERE link IS NOT NULL;
 
 -- ============================================================
 -- Regular table: Aggregate Sentiment (UPSERT pattern, not time-series)
@@ -150,8 +257,8 @@ CREATE TABLE IF NOT EXISTS aggregate_sentiment (
     UNIQUE(symbol, period_start, period_end)
 );
 
-CREATE INDEX idx_aggregate_sentiment_symbol_date ON aggregate_sentiment(symbol, analyzed_at DESC);
-CREATE INDEX idx_aggregate_sentiment_period ON aggregate_sentiment(period_start, period_end);
+CREATE INDEX IF NOT EXISTS idx_aggregate_sentiment_symbol_date ON aggregate_sentiment(symbol, analyzed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_aggregate_sentiment_period ON aggregate_sentiment(period_start, period_end);
 
 -- ============================================================
 -- Hypertable: Historical Chart Data
@@ -174,7 +281,54 @@ CREATE TABLE IF NOT EXISTS chart_data (
 
 SELECT create_hypertable('chart_data', 'fetched_at', if_not_exists => TRUE);
 
-CREATE INDEX idx_chart_data_symbol_date ON chart_data(symbol, date DESC);
+CREATE INDEX IF NOT EXISTS idx_chart_data_symbol_date ON chart_data(symbol, date DESC);
+
+-- ============================================================
+-- Regular table: Background Job Runs (one row per run, updated on finish)
+-- ============================================================
+CREATE TABLE IF NOT EXISTS job_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    job_name VARCHAR(100) NOT NULL,
+    trigger VARCHAR(20) NOT NULL,       -- schedule | manual
+    status VARCHAR(20) NOT NULL,        -- running | succeeded | partial | failed
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMPTZ,
+    items_processed INT NOT NULL DEFAULT 0,
+    items_failed INT NOT NULL DEFAULT 0,
+    error_message TEXT,
+    failures JSONB                      -- per-item failure messages
+);
+
+CREATE INDEX IF NOT EXISTS idx_job_runs_name_started ON job_runs(job_name, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_job_runs_status ON job_runs(status) WHERE status = 'running';
+
+-- ============================================================
+-- Regular table: Analysis Jobs (asynchronous requests, updated on each
+-- state change; the result is stored when the job finishes)
+-- ============================================================
+CREATE TABLE IF NOT EXISTS analysis_jobs (
+    id UUID PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,          -- news_sentiment | sentiment | general_news_sentiment | sentiment_batch
+    status VARCHAR(20) NOT NULL,        -- queued | running | completed | failed | cancelled
+    params JSONB,
+    total INT NOT NULL DEFAULT 0,
+    processed INT NOT NULL DEFAULT 0,
+    failed INT NOT NULL DEFAULT 0,
+    result JSONB,
+    error_message TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    owner VARCHAR(255),                 -- JOB_INSTANCE_ID of the process running the job
+    heartbeat_at TIMESTAMPTZ            -- refreshed by that process while the job is unfinished
+);
+
+-- Upgrade: job ownership, so a replica only abandons its own or stale jobs
+ALTER TABLE analysis_jobs ADD COLUMN IF NOT EXISTS owner VARCHAR(255);
+ALTER TABLE analysis_jobs ADD COLUMN IF NOT EXISTS heartbeat_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_created ON analysis_jobs(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_analysis_jobs_status ON analysis_jobs(status) WHERE status IN ('queued', 'running');
 
 -- ============================================================
 -- View: recent sentiment with news (join via news_item_id)
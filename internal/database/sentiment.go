@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/MadebyDaris/dogonomics/BertInference"
 	"github.com/google/uuid"
 )
 
@@ -33,8 +34,17 @@ type SentimentAnalysis struct {
 	PositiveScore   *float64
 	NeutralScore    *float64
 	NegativeScore   *float64
+	PositiveLogit   *float64
+	NeutralLogit    *float64
+	NegativeLogit   *float64
 	ModelVersion    *string
 	InferenceTimeMS *int
+
+	// Provider's own sentiment for the same article, when available
+	Provider          *string
+	ProviderScore     *float64
+	ProviderLabel     *string
+	ProviderRelevance *float64
 }
 
 // AggregateSentiment represents aggregate sentiment for a symbol over a period
@@ -92,8 +102,10 @@ func SaveSentimentAnalysis(ctx context.Context, sentiment *SentimentAnalysis) er
 		INSERT INTO sentiment_analysis (
 			news_item_id, symbol, bert_label, bert_confidence, bert_score,
 			polarity, positive_score, neutral_score, negative_score,
-			model_version, inference_time_ms
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			positive_logit, neutral_logit, negative_logit,
+			model_version, inference_time_ms,
+			provider, provider_score, provider_label, provider_relevance
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 	`
 
 	_, err := DB.Exec(ctx, query,
@@ -106,8 +118,15 @@ func SaveSentimentAnalysis(ctx context.Context, sentiment *SentimentAnalysis) er
 		sentiment.PositiveScore,
 		sentiment.NeutralScore,
 		sentiment.NegativeScore,
+		sentiment.PositiveLogit,
+		sentiment.NeutralLogit,
+		sentiment.NegativeLogit,
 		sentiment.ModelVersion,
 		sentiment.InferenceTimeMS,
+		sentiment.Provider,
+		sentiment.ProviderScore,
+		sentiment.ProviderLabel,
+		sentiment.ProviderRelevance,
 	)
 
 	return err
@@ -151,6 +170,30 @@ func SaveAggregateSentiment(ctx context.Context, agg *AggregateSentiment) error
 	return err
 }
 
+// bertSentimentRecord maps a FinBERT result, including its class
+// probabilities, logits and the exact model version, onto a
+// sentiment_analysis row for symbol
+func bertSentimentRecord(symbol string, sentiment *BertInference.BERTSentiment) *SentimentAnalysis {
+	var modelVersion *string
+	if sentiment.Model != "" {
+		modelVersion = &sentiment.Model
+	}
+	probs, logits := sentiment.Probabilities, sentiment.Logits
+	return &SentimentAnalysis{
+		Symbol:         symbol,
+		BERTLabel:      &sentiment.Label,
+		BERTConfidence: &sentiment.Confidence,
+		BERTScore:      &sentiment.Score,
+		PositiveScore:  &probs.Positive,
+		NeutralScore:   &probs.Neutral,
+		NegativeScore:  &probs.Negative,
+		PositiveLogit:  &logits.Positive,
+		NeutralLogit:   &logits.Neutral,
+		NegativeLogit:  &logits.Negative,
+		ModelVersion:   modelVersion,
+	}
+}
+
 // GetSentimentHistory retrieves sentiment history for a symbol
 func GetSentimentHistory(ctx context.Context, symbol string, days int) ([]SentimentAnalysis, error) {
 	if DB == nil {
@@ -159,7 +202,8 @@ func GetSentimentHistory(ctx context.Context, symbol string, days int) ([]Sentim
 
 	query := `
 		SELECT id, news_item_id, symbol, analyzed_at, bert_label, bert_confidence,
-		       bert_score, polarity, positive_score, neutral_score, negative_score
+		       bert_score, polarity, positive_score, neutral_score, negative_score,
+		       positive_logit, neutral_logit, negative_logit
 		FROM sentiment_analysis
 		WHERE symbol = $1
 		  AND analyzed_at >= NOW() - ($2 || ' days')::INTERVAL
@@ -187,6 +231,9 @@ func GetSentimentHistory(ctx context.Context, symbol string, days int) ([]Sentim
 			&sa.PositiveScore,
 			&sa.NeutralScore,
 			&sa.NegativeScore,
+			&sa.PositiveLogit,
+			&sa.NeutralLogit,
+			&sa.NegativeLogit,
 		)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// ShadowSentiment pairs a candidate model's result with the production
+// result for the same article and ticker
+type ShadowSentiment struct {
+	Symbol               string
+	AnalyzedAt           time.Time
+	Link                 *string
+	ProductionModel      string
+	ProductionLabel      string
+	ProductionConfidence float64
+	ProductionScore      float64
+	CandidateModel       string
+	CandidateLabel       string
+	CandidateConfidence  float64
+	CandidateScore       float64
+}
+
+// ShadowComparison summarises how a candidate model compares with the
+// production model over a period. ScoreDrift is the mean of candidate minus
+// production score; Confusion counts articles by production label, then
+// candidate label.
+type ShadowComparison struct {
+	ProductionModel    string                      `json:"production_model"`
+	CandidateModel     string                      `json:"candidate_model"`
+	Pairs              int64                       `json:"pairs"`
+	AgreementRate      *float64                    `json:"agreement_rate"`
+	AvgProductionScore *float64                    `json:"avg_production_score"`
+	AvgCandidateScore  *float64                    `json:"avg_candidate_score"`
+	ScoreDrift         *float64                    `json:"score_drift"`
+	MeanAbsDifference  *float64                    `json:"mean_abs_difference"`
+	Correlation        *float64                    `json:"correlation"`
+	Confusion          map[string]map[string]int64 `json:"confusion"`
+}
+
+// SaveShadowSentiment stores one production/candidate pair
+func SaveShadowSentiment(ctx context.Context, s *ShadowSentiment) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
+
+	query := `
+		INSERT INTO shadow_sentiment (
+			symbol, link,
+			production_model, production_label, production_confidence, production_score,
+			candidate_model, candidate_label, candidate_confidence, candidate_score
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	_, err := DB.Exec(ctx, query,
+		s.Symbol,
+		s.Link,
+		s.ProductionModel,
+		s.ProductionLabel,
+		s.ProductionConfidence,
+		s.ProductionScore,
+		s.CandidateModel,
+		s.CandidateLabel,
+		s.CandidateConfidence,
+		s.CandidateScore,
+	)
+	return err
+}
+
+// ShadowedLinks returns which of the given article links a candidate model
+// has already scored against the production model, so articles seen again
+// are not counted twice
+func ShadowedLinks(ctx context.Context, productionModel, candidateModel string, links []string) (map[string]bool, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	rows, err := DB.Query(ctx, `
+		SELECT DISTINCT link FROM shadow_sentiment
+		WHERE candidate_model = $1 AND production_model = $2 AND link = ANY($3)
+	`, candidateModel, productionModel, links)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var link string
+		if err := rows.Scan(&link); err != nil {
+			return nil, err
+		}
+		seen[link] = true
+	}
+	return seen, rows.Err()
+}
+
+// GetShadowComparison compares every candidate model with the production
+// model it shadowed for a symbol over the last days. candidateModel
+// restricts the result to one candidate (name@version) when not empty.
+func GetShadowComparison(ctx context.Context, symbol, candidateModel string, days int) ([]ShadowComparison, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	query := `
+		SELECT
+			production_model,
+			candidate_model,
+			COUNT(*) AS pairs,
+			AVG(CASE WHEN production_label = candidate_label THEN 1.0 ELSE 0.0 END)::FLOAT8,
+			AVG(production_score)::FLOAT8,
+			AVG(candidate_score)::FLOAT8,
+			AVG(candidate_score - production_score)::FLOAT8,
+			AVG(ABS(candidate_score - production_score))::FLOAT8,
+			CORR(production_score, candidate_score)
+		FROM shadow_sentiment
+		WHERE symbol = $1
+		  AND analyzed_at >= NOW() - make_interval(days => $2)
+		  AND ($3 = '' OR candidate_model = $3)
+		GROUP BY production_model, candidate_model
+		ORDER BY candidate_model, production_model
+	`
+
+	rows, err := DB.Query(ctx, query, symbol, days, candidateModel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []ShadowComparison
+	index := make(map[[2]string]int)
+	for rows.Next() {
+		var sc ShadowComparison
+		err := rows.Scan(
+			&sc.ProductionModel,
+			&sc.CandidateModel,
+			&sc.Pairs,
+			&sc.AgreementRate,
+			&sc.AvgProductionScore,
+			&sc.AvgCandidateScore,
+			&sc.ScoreDrift,
+			&sc.MeanAbsDifference,
+			&sc.Correlation,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sc.Confusion = make(map[string]map[string]int64)
+		index[[2]string{sc.ProductionModel, sc.CandidateModel}] = len(results)
+		results = append(results, sc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	confusion, err := DB.Query(ctx, `
+		SELECT production_model, candidate_model, production_label, candidate_label, COUNT(*)
+		FROM shadow_sentiment
+		WHERE symbol = $1
+		  AND analyzed_at >= NOW() - make_interval(days => $2)
+		  AND ($3 = '' OR candidate_model = $3)
+		GROUP BY production_model, candidate_model, production_label, candidate_label
+	`, symbol, days, candidateModel)
+	if err != nil {
+		return nil, err
+	}
+	defer confusion.Close()
+
+	for confusion.Next() {
+		var production, candidate, productionLabel, candidateLabel string
+		var count int64
+		if err := confusion.Scan(&production, &candidate, &productionLabel, &candidateLabel, &count); err != nil {
+			return nil, err
+		}
+		i, ok := index[[2]string{production, candidate}]
+		if !ok {
+			continue
+		}
+		row := results[i].Confusion[productionLabel]
+		if row == nil {
+			row = make(map[string]int64)
+			results[i].Confusion[productionLabel] = row
+		}
+		row[candidateLabel] = count
+	}
+	return results, confusion.Err()
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/MadebyDaris/dogonomics/internal/DogonomicsFetching"
+	"github.com/MadebyDaris/dogonomics/internal/DogonomicsProcessing"
 	"github.com/MadebyDaris/dogonomics/sentAnalysis"
 	"github.com/google/uuid"
 )
@@ -42,6 +43,109 @@ func SaveStockQuote(ctx context.Context, symbol string, quote *DogonomicsFetchin
 	return err
 }
 
+// SaveChartData stores daily bars, skipping dates already stored for the
+// same symbol and source. Returns the number of newly inserted bars.
+func SaveChartData(ctx context.Context, symbol, source string, points []DogonomicsProcessing.ChartDataPoint) (int, error) {
+	if DB == nil {
+		return 0, ErrDatabaseNotConnected
+	}
+
+	query := `
+		INSERT INTO chart_data (symbol, date, open_price, high_price, low_price, close_price, volume, source)
+		SELECT $1, $2::DATE, $3, $4, $5, $6, $7, $8
+		WHERE NOT EXISTS (
+			SELECT 1 FROM chart_data WHERE symbol = $1 AND date = $2::DATE AND source = $8
+		)
+	`
+
+	inserted := 0
+	for _, p := range points {
+		tag, err := DB.Exec(ctx, query,
+			symbol,
+			p.Timestamp.UTC().Format("2006-01-02"),
+			p.Open,
+			p.High,
+			p.Low,
+			p.Close,
+			p.Volume,
+			source,
+		)
+		if err != nil {
+			return inserted, err
+		}
+		inserted += int(tag.RowsAffected())
+	}
+
+	return inserted, nil
+}
+
+// SaveCompanyProfile upserts a Finnhub company profile into company_profiles
+func SaveCompanyProfile(ctx context.Context, symbol string, profile *DogonomicsProcessing.CompanyProfile) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
+
+	rawData, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO company_profiles (symbol, name, country, currency, exchange, industry, market_cap, logo_url, website_url, raw_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (symbol) DO UPDATE SET
+			name = EXCLUDED.name,
+			country = EXCLUDED.country,
+			currency = EXCLUDED.currency,
+			exchange = EXCLUDED.exchange,
+			industry = EXCLUDED.industry,
+			market_cap = EXCLUDED.market_cap,
+			logo_url = EXCLUDED.logo_url,
+			website_url = EXCLUDED.website_url,
+			raw_data = EXCLUDED.raw_data,
+			last_updated = NOW()
+	`
+
+	_, err = DB.Exec(ctx, query,
+		symbol,
+		profile.Name,
+		profile.Country,
+		profile.Currency,
+		profile.Exchange,
+		profile.FinnhubIndustry,
+		int64(profile.MarketCap*1e6), // Finnhub reports market cap in millions
+		profile.Logo,
+		profile.WebURL,
+		rawData,
+	)
+
+	return err
+}
+
+// GetCompanyNames returns symbol -> company name for every stored profile
+func GetCompanyNames(ctx context.Context) (map[string]string, error) {
+	if DB == nil {
+		return nil, ErrDatabaseNotConnected
+	}
+
+	rows, err := DB.Query(ctx, `SELECT symbol, name FROM company_profiles WHERE name IS NOT NULL AND name <> ''`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var symbol, name string
+		if err := rows.Scan(&symbol, &name); err != nil {
+			return nil, err
+		}
+		names[symbol] = name
+	}
+
+	return names, rows.Err()
+}
+
 // SaveNewsWithSentiment saves a news item from sentAnalysis package and returns the generated ID
 func SaveNewsWithSentiment(ctx context.Context, symbol string, news *sentAnalysis.NewsItem) (uuid.UUID, error) {
 	if DB == nil {
@@ -66,8 +170,8 @@ func SaveNewsWithSentiment(ctx context.Context, symbol string, news *sentAnalysi
 	}
 
 	query := `
-		INSERT INTO news_items (symbol, title, content, published_date, source, link, tags)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO news_items (symbol, title, content, published_date, source, link, tags, symbols)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (symbol, link, fetched_at) DO UPDATE SET
 			title = EXCLUDED.title,
 			content = EXCLUDED.content
@@ -88,45 +192,38 @@ func SaveNewsWithSentiment(ctx context.Context, symbol string, news *sentAnalysi
 		source,
 		news.Link,
 		news.Tags,
+		[]string(news.Symbols),
 	).Scan(&id)
 
 	return id, err
 }
 
-// SaveNewsSentiment saves BERT sentiment analysis results for a news item
-func SaveNewsSentiment(ctx context.Context, newsItemID uuid.UUID, news *sentAnalysis.NewsItem) error {
+// NewsLinkExists reports whether an article link is already stored for a symbol
+func NewsLinkExists(ctx context.Context, symbol, link string) (bool, error) {
 	if DB == nil {
-		return ErrDatabaseNotConnected
+		return false, ErrDatabaseNotConnected
 	}
 
-	// Extract symbol from news.Symbols array
-	symbol := ""
-	if len(news.Symbols) > 0 {
-		symbol = news.Symbols[0]
-	}
+	var exists bool
+	err := DB.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM news_items WHERE symbol = $1 AND link = $2)`,
+		symbol, link,
+	).Scan(&exists)
+	return exists, err
+}
 
-	query := `
-		INSERT INTO sentiment_analysis (
-			news_item_id, symbol, bert_label, bert_confidence, bert_score,
-			polarity, positive_score, neutral_score, negative_score,
-			model_version
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
+// SaveNewsSentiment saves BERT sentiment analysis results for a news item,
+// attributed to the given ticker. Call it once per ticker the article mentions.
+func SaveNewsSentiment(ctx context.Context, newsItemID uuid.UUID, symbol string, news *sentAnalysis.NewsItem) error {
+	if DB == nil {
+		return ErrDatabaseNotConnected
+	}
 
-	_, err := DB.Exec(ctx, query,
-		newsItemID,
-		symbol,
-		news.BERTSentiment.Label,
-		news.BERTSentiment.Confidence,
-		news.BERTSentiment.Score,
-		news.Sentiment.Polarity,
-		news.Sentiment.Pos,
-		news.Sentiment.Neu,
-		news.Sentiment.Neg,
-		"FinBERT-ONNX",
-	)
+	record := bertSentimentRecord(symbol, &news.BERTSentiment)
+	record.NewsItemID = &newsItemID
+	record.Polarity = &news.Sentiment.Polarity
 
-	return err
+	return SaveSentimentAnalysis(ctx, record)
 }
 
 // SaveAggregatedSentiment saves aggregate sentiment analysis for a symbol
@@ -0,0 +1,168 @@
+package evaluation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+)
+
+// FitCalibration fits a calibration of the given method to the raw logits
+// of predictions made on labelled examples. Fit on a held-out set: the ECE
+// recorded on the result is measured on the same examples and so is
+// optimistic.
+func FitCalibration(method string, examples []Example, predictions []Prediction) (*BertInference.Calibration, error) {
+	if len(examples) != len(predictions) {
+		return nil, fmt.Errorf("%d examples but %d predictions", len(examples), len(predictions))
+	}
+	if len(examples) == 0 {
+		return nil, fmt.Errorf("no examples")
+	}
+
+	logits := make([][]float64, len(predictions))
+	truth := make([]int, len(examples))
+	for i, pred := range predictions {
+		logits[i] = logitsRow(pred.Logits)
+		truth[i] = labelIndex(examples[i].Label)
+	}
+
+	var calibration *BertInference.Calibration
+	switch method {
+	case BertInference.CalibrationTemperature:
+		calibration = &BertInference.Calibration{Method: method, Temperature: fitTemperature(logits, truth)}
+	case BertInference.CalibrationIsotonic:
+		calibration = &BertInference.Calibration{Method: method, Isotonic: fitIsotonic(logits, truth)}
+	default:
+		return nil, fmt.Errorf("unknown calibration method %q", method)
+	}
+	if err := calibration.Validate(); err != nil {
+		return nil, err
+	}
+
+	before, err := Evaluate(examples, Recalibrate(predictions, nil))
+	if err != nil {
+		return nil, err
+	}
+	after, err := Evaluate(examples, Recalibrate(predictions, calibration))
+	if err != nil {
+		return nil, err
+	}
+	calibration.Examples = len(examples)
+	calibration.ECEBefore = before.ECE
+	calibration.ECEAfter = after.ECE
+	return calibration, nil
+}
+
+// Recalibrate recomputes each prediction's label and confidence from its
+// raw logits with the given calibration, or a plain softmax when nil
+func Recalibrate(predictions []Prediction, calibration *BertInference.Calibration) []Prediction {
+	out := make([]Prediction, len(predictions))
+	for i, pred := range predictions {
+		probs := calibration.Probabilities(logitsRow(pred.Logits), BertInference.DefaultLabels)
+		out[i] = pred
+		out[i].Confidence = 0
+		for k, p := range probs {
+			if p > out[i].Confidence {
+				out[i].Confidence = p
+				out[i].Label = BertInference.DefaultLabels[k]
+			}
+		}
+	}
+	return out
+}
+
+func logitsRow(logits BertInference.ClassScores) []float64 {
+	row := make([]float64, len(BertInference.DefaultLabels))
+	for k, label := range BertInference.DefaultLabels {
+		row[k] = logits.Get(label)
+	}
+	return row
+}
+
+func labelIndex(label string) int {
+	for k, l := range BertInference.DefaultLabels {
+		if l == label {
+			return k
+		}
+	}
+	return -1
+}
+
+// fitTemperature finds the temperature minimising the negative
+// log-likelihood of the true labels. The NLL is convex in the inverse
+// temperature, so a golden-section search over it converges.
+func fitTemperature(logits [][]float64, truth []int) float64 {
+	nll := func(inverse float64) float64 {
+		calibration := &BertInference.Calibration{Method: BertInference.CalibrationTemperature, Temperature: 1 / inverse}
+		var total float64
+		for i, row := range logits {
+			p := calibration.Probabilities(row, BertInference.DefaultLabels)[truth[i]]
+			total -= math.Log(max(p, 1e-12))
+		}
+		return total
+	}
+
+	phi := (math.Sqrt(5) - 1) / 2
+	lo, hi := 0.01, 10.0
+	a, b := hi-phi*(hi-lo), lo+phi*(hi-lo)
+	fa, fb := nll(a), nll(b)
+	for hi-lo > 1e-6 {
+		if fa < fb {
+			hi, b, fb = b, a, fa
+			a = hi - phi*(hi-lo)
+			fa = nll(a)
+		} else {
+			lo, a, fa = a, b, fb
+			b = lo + phi*(hi-lo)
+			fb = nll(b)
+		}
+	}
+	return 1 / ((lo + hi) / 2)
+}
+
+// fitIsotonic fits one-vs-rest isotonic regression of "is this class" on
+// each class's softmax probability, using pool adjacent violators
+func fitIsotonic(logits [][]float64, truth []int) map[string]BertInference.IsotonicCurve {
+	probs := make([][]float64, len(logits))
+	for i, row := range logits {
+		probs[i] = (*BertInference.Calibration)(nil).Probabilities(row, BertInference.DefaultLabels)
+	}
+
+	curves := make(map[string]BertInference.IsotonicCurve, len(BertInference.DefaultLabels))
+	for k, label := range BertInference.DefaultLabels {
+		order := make([]int, len(probs))
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool { return probs[order[a]][k] < probs[order[b]][k] })
+
+		// Each block holds the summed x and y of a run of points and is
+		// merged with its predecessor while their means are out of order
+		type block struct{ x, y, n float64 }
+		var blocks []block
+		for _, i := range order {
+			y := 0.0
+			if truth[i] == k {
+				y = 1
+			}
+			blocks = append(blocks, block{probs[i][k], y, 1})
+			for len(blocks) > 1 {
+				last, prev := blocks[len(blocks)-1], blocks[len(blocks)-2]
+				if prev.y/prev.n < last.y/last.n {
+					break
+				}
+				blocks = blocks[:len(blocks)-1]
+				blocks[len(blocks)-1] = block{prev.x + last.x, prev.y + last.y, prev.n + last.n}
+			}
+		}
+
+		curve := BertInference.IsotonicCurve{X: make([]float64, len(blocks)), Y: make([]float64, len(blocks))}
+		for i, b := range blocks {
+			curve.X[i] = b.x / b.n
+			curve.Y[i] = b.y / b.n
+		}
+		curves[label] = curve
+	}
+	return curves
+}
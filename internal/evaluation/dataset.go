@@ -0,0 +1,120 @@
+package evaluation
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+)
+
+// Example is one labelled sentence
+type Example struct {
+	Text  string `json:"text"`
+	Label string `json:"label"`
+}
+
+// LoadDataset reads labelled examples from a .jsonl file (one
+// {"text": ..., "label": ...} object per line) or a .csv file with "text"
+// and "label" columns. Labels must be positive, neutral or negative in any
+// case.
+func LoadDataset(path string) ([]Example, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var examples []Example
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		examples, err = readJSONL(file)
+	case ".csv":
+		examples, err = readCSV(file)
+	default:
+		return nil, fmt.Errorf("unsupported dataset format %q: use .csv or .jsonl", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(examples) == 0 {
+		return nil, fmt.Errorf("%s: no examples", path)
+	}
+	return examples, nil
+}
+
+func readJSONL(r io.Reader) ([]Example, error) {
+	var examples []Example
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var ex Example
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if err := normalize(&ex); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		examples = append(examples, ex)
+	}
+	return examples, scanner.Err()
+}
+
+func readCSV(r io.Reader) ([]Example, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing header: %v", err)
+	}
+	textCol, labelCol := -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "text", "sentence":
+			textCol = i
+		case "label", "sentiment":
+			labelCol = i
+		}
+	}
+	if textCol < 0 || labelCol < 0 {
+		return nil, fmt.Errorf("header needs text and label columns, got %v", header)
+	}
+
+	var examples []Example
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		ex := Example{Text: record[textCol], Label: record[labelCol]}
+		if err := normalize(&ex); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		examples = append(examples, ex)
+	}
+	return examples, nil
+}
+
+func normalize(ex *Example) error {
+	ex.Text = strings.TrimSpace(ex.Text)
+	if ex.Text == "" {
+		return fmt.Errorf("empty text")
+	}
+	ex.Label = strings.ToLower(strings.TrimSpace(ex.Label))
+	switch ex.Label {
+	case BertInference.LabelPositive, BertInference.LabelNeutral, BertInference.LabelNegative:
+		return nil
+	}
+	return fmt.Errorf("unknown label %q", ex.Label)
+}
@@ -0,0 +1,259 @@
+// Package evaluation scores a sentiment model's predictions against a
+// labelled dataset: accuracy, per-class precision/recall/F1, expected
+// calibration error and latency percentiles, and checks a report against a
+// stored baseline.
+package evaluation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+)
+
+// Classes in report order
+var Classes = []string{BertInference.LabelPositive, BertInference.LabelNeutral, BertInference.LabelNegative}
+
+// calibrationBins is the number of equal-width confidence bins used for ECE
+const calibrationBins = 10
+
+// Prediction is the model's output for one example
+type Prediction struct {
+	Label      string
+	Confidence float64
+	// Logits are the raw model outputs, used to fit calibration
+	Logits  BertInference.ClassScores
+	Latency time.Duration
+}
+
+// ClassMetrics are one-vs-rest metrics for a class
+type ClassMetrics struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// CalibrationBin is one confidence bucket of the reliability diagram
+type CalibrationBin struct {
+	Lower          float64 `json:"lower"`
+	Upper          float64 `json:"upper"`
+	Count          int     `json:"count"`
+	MeanConfidence float64 `json:"mean_confidence"`
+	Accuracy       float64 `json:"accuracy"`
+}
+
+// Latency holds percentiles of per-example inference latency in
+// milliseconds
+type Latency struct {
+	P50  float64 `json:"p50_ms"`
+	P90  float64 `json:"p90_ms"`
+	P95  float64 `json:"p95_ms"`
+	P99  float64 `json:"p99_ms"`
+	Max  float64 `json:"max_ms"`
+	Mean float64 `json:"mean_ms"`
+}
+
+// Report is the result of one evaluation run
+type Report struct {
+	Model    string                  `json:"model"`
+	Dataset  string                  `json:"dataset"`
+	Examples int                     `json:"examples"`
+	Accuracy float64                 `json:"accuracy"`
+	MacroF1  float64                 `json:"macro_f1"`
+	Classes  map[string]ClassMetrics `json:"classes"`
+	// Confusion counts examples by true label, then predicted label
+	Confusion   map[string]map[string]int `json:"confusion"`
+	ECE         float64                   `json:"ece"`
+	Calibration []CalibrationBin          `json:"calibration"`
+	Latency     Latency                   `json:"latency"`
+	RanAt       time.Time                 `json:"ran_at"`
+}
+
+// Evaluate compares predictions with the examples they were made for
+func Evaluate(examples []Example, predictions []Prediction) (*Report, error) {
+	if len(examples) != len(predictions) {
+		return nil, fmt.Errorf("%d examples but %d predictions", len(examples), len(predictions))
+	}
+	if len(examples) == 0 {
+		return nil, fmt.Errorf("no examples")
+	}
+
+	report := &Report{
+		Examples:  len(examples),
+		Classes:   make(map[string]ClassMetrics, len(Classes)),
+		Confusion: make(map[string]map[string]int, len(Classes)),
+		RanAt:     time.Now().UTC(),
+	}
+	for _, class := range Classes {
+		report.Confusion[class] = make(map[string]int, len(Classes))
+	}
+
+	correct := 0
+	for i, ex := range examples {
+		pred := predictions[i].Label
+		report.Confusion[ex.Label][pred]++
+		if pred == ex.Label {
+			correct++
+		}
+	}
+	report.Accuracy = float64(correct) / float64(len(examples))
+
+	for _, class := range Classes {
+		var tp, predicted, actual int
+		for _, truth := range Classes {
+			for _, pred := range Classes {
+				n := report.Confusion[truth][pred]
+				if truth == class {
+					actual += n
+				}
+				if pred == class {
+					predicted += n
+				}
+				if truth == class && pred == class {
+					tp += n
+				}
+			}
+		}
+		m := ClassMetrics{
+			Precision: ratio(tp, predicted),
+			Recall:    ratio(tp, actual),
+			Support:   actual,
+		}
+		if m.Precision+m.Recall > 0 {
+			m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+		}
+		report.Classes[class] = m
+		report.MacroF1 += m.F1 / float64(len(Classes))
+	}
+
+	report.ECE, report.Calibration = calibration(examples, predictions)
+	report.Latency = latencyPercentiles(predictions)
+	return report, nil
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// calibration bins predictions by confidence and returns the expected
+// calibration error: the example-weighted mean gap between confidence and
+// accuracy across bins
+func calibration(examples []Example, predictions []Prediction) (float64, []CalibrationBin) {
+	bins := make([]CalibrationBin, calibrationBins)
+	correct := make([]int, calibrationBins)
+	for i := range bins {
+		bins[i].Lower = float64(i) / calibrationBins
+		bins[i].Upper = float64(i+1) / calibrationBins
+	}
+
+	for i, pred := range predictions {
+		b := min(int(pred.Confidence*calibrationBins), calibrationBins-1)
+		b = max(b, 0)
+		bins[b].Count++
+		bins[b].MeanConfidence += pred.Confidence
+		if pred.Label == examples[i].Label {
+			correct[b]++
+		}
+	}
+
+	var ece float64
+	for i := range bins {
+		if bins[i].Count == 0 {
+			continue
+		}
+		bins[i].MeanConfidence /= float64(bins[i].Count)
+		bins[i].Accuracy = float64(correct[i]) / float64(bins[i].Count)
+		ece += float64(bins[i].Count) / float64(len(predictions)) * math.Abs(bins[i].Accuracy-bins[i].MeanConfidence)
+	}
+	return ece, bins
+}
+
+func latencyPercentiles(predictions []Prediction) Latency {
+	ms := make([]float64, len(predictions))
+	var total float64
+	for i, pred := range predictions {
+		ms[i] = float64(pred.Latency) / float64(time.Millisecond)
+		total += ms[i]
+	}
+	slices.Sort(ms)
+
+	percentile := func(p float64) float64 {
+		// Nearest-rank
+		rank := int(math.Ceil(p/100*float64(len(ms)))) - 1
+		return ms[max(rank, 0)]
+	}
+	return Latency{
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P95:  percentile(95),
+		P99:  percentile(99),
+		Max:  ms[len(ms)-1],
+		Mean: total / float64(len(ms)),
+	}
+}
+
+// LoadReport reads a report written with SaveReport
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid report %s: %v", path, err)
+	}
+	return &report, nil
+}
+
+// SaveReport writes a report as indented JSON
+func SaveReport(path string, report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Regression is a metric that got worse than the baseline by more than the
+// tolerance
+type Regression struct {
+	Metric   string  `json:"metric"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %.4f -> %.4f", r.Metric, r.Baseline, r.Current)
+}
+
+// Compare lists quality metrics that regressed against the baseline:
+// accuracy, macro F1 and per-class F1 dropping, or ECE rising, by more than
+// tolerance. Latency is machine dependent and not compared.
+func Compare(current, baseline *Report, tolerance float64) []Regression {
+	var regressions []Regression
+	lower := func(metric string, base, cur float64) {
+		if cur < base-tolerance {
+			regressions = append(regressions, Regression{Metric: metric, Baseline: base, Current: cur})
+		}
+	}
+
+	lower("accuracy", baseline.Accuracy, current.Accuracy)
+	lower("macro_f1", baseline.MacroF1, current.MacroF1)
+	for _, class := range Classes {
+		if base, ok := baseline.Classes[class]; ok {
+			lower(class+".f1", base.F1, current.Classes[class].F1)
+		}
+	}
+	if current.ECE > baseline.ECE+tolerance {
+		regressions = append(regressions, Regression{Metric: "ece", Baseline: baseline.ECE, Current: current.ECE})
+	}
+	return regressions
+}
@@ -0,0 +1,262 @@
+// Package ingestion defines the scheduled jobs that keep quotes, daily bars,
+// news and sentiment history filled in for a configured symbol universe,
+// independently of which endpoints users happen to call.
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/MadebyDaris/dogonomics/internal/DogonomicsFetching"
+	"github.com/MadebyDaris/dogonomics/internal/NewsClient"
+	"github.com/MadebyDaris/dogonomics/internal/PolygonClient"
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
+	"github.com/MadebyDaris/dogonomics/internal/database"
+	"github.com/MadebyDaris/dogonomics/internal/scheduler"
+	"github.com/MadebyDaris/dogonomics/internal/shadow"
+	"github.com/MadebyDaris/dogonomics/internal/workerpool"
+	"github.com/MadebyDaris/dogonomics/sentAnalysis"
+)
+
+// Config holds the symbol universe and job schedules. A schedule of "off"
+// disables that job.
+type Config struct {
+	Symbols           []string
+	Workers           int
+	QuotesSchedule    string
+	BarsSchedule      string
+	BarsDays          int
+	NewsSchedule      string
+	NewsLimit         int
+	SentimentSchedule string
+}
+
+// LoadConfigFromEnv loads ingestion configuration from environment variables
+func LoadConfigFromEnv() *Config {
+	return &Config{
+		Symbols:           SymbolLinker.SplitSymbols(os.Getenv("INGEST_SYMBOLS")),
+		Workers:           getEnvInt("INGEST_WORKERS", 3),
+		QuotesSchedule:    getEnv("INGEST_QUOTES_SCHEDULE", "*/5 13-21 * * 1-5"), // US market hours, UTC
+		BarsSchedule:      getEnv("INGEST_BARS_SCHEDULE", "30 22 * * 1-5"),       // after the close
+		BarsDays:          getEnvInt("INGEST_BARS_DAYS", 7),
+		NewsSchedule:      getEnv("INGEST_NEWS_SCHEDULE", "@every 30m"),
+		NewsLimit:         getEnvInt("INGEST_NEWS_LIMIT", 20),
+		SentimentSchedule: getEnv("INGEST_SENTIMENT_SCHEDULE", "0 */2 * * *"),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value := strings.TrimSpace(os.Getenv(key)); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return fallback
+}
+
+// Jobs builds the ingestion jobs for the configured universe. It returns
+// nil when no symbols are configured.
+func Jobs(cfg *Config, finnhub *DogonomicsFetching.Client, news *NewsClient.NewsClient) []scheduler.Job {
+	if len(cfg.Symbols) == 0 {
+		return nil
+	}
+
+	var jobs []scheduler.Job
+	add := func(spec string, job scheduler.Job) {
+		if strings.EqualFold(spec, "off") {
+			return
+		}
+		job.Spec = spec
+		jobs = append(jobs, job)
+	}
+
+	add(cfg.QuotesSchedule, scheduler.Job{
+		Name:        "quotes",
+		Description: "Finnhub quotes -> stock_quotes",
+		Timeout:     2 * time.Minute,
+		Run: func(ctx context.Context, report *scheduler.Report) error {
+			return forEachSymbol(ctx, cfg, report, func(ctx context.Context, symbol string) (int, error) {
+				quote, err := finnhub.GetQuote(ctx, symbol)
+				if err != nil {
+					return 0, err
+				}
+				// Finnhub answers unknown symbols with an all-zero quote
+				if quote.CurrentPrice == 0 && quote.Timestamp == 0 {
+					return 0, fmt.Errorf("no quote returned")
+				}
+				return 1, database.SaveStockQuote(ctx, symbol, quote)
+			})
+		},
+	})
+
+	if os.Getenv("POLYGON_API_KEY") != "" {
+		add(cfg.BarsSchedule, scheduler.Job{
+			Name:        "bars",
+			Description: "Polygon daily bars -> chart_data",
+			Run: func(ctx context.Context, report *scheduler.Report) error {
+				return forEachSymbol(ctx, cfg, report, func(ctx context.Context, symbol string) (int, error) {
+					points, err := PolygonClient.RequestHistoricalData(ctx, symbol, cfg.BarsDays)
+					if err != nil {
+						return 0, err
+					}
+					// Today's bar is still forming; it is picked up on the next run
+					today := time.Now().UTC().Truncate(24 * time.Hour)
+					complete := points[:0]
+					for _, p := range points {
+						if p.Timestamp.Before(today) {
+							complete = append(complete, p)
+						}
+					}
+					return database.SaveChartData(ctx, symbol, "polygon", complete)
+				})
+			},
+		})
+	}
+
+	add(cfg.NewsSchedule, scheduler.Job{
+		Name:        "news",
+		Description: "Multi-source news -> news_items archive, provider sentiment -> sentiment_analysis",
+		Run: func(ctx context.Context, report *scheduler.Report) error {
+			return forEachSymbol(ctx, cfg, report, func(ctx context.Context, symbol string) (int, error) {
+				articles, err := news.GetNewsBySymbol(ctx, symbol, cfg.NewsLimit)
+				if err != nil {
+					return 0, err
+				}
+				archived, err := database.ArchiveNewsArticles(ctx, symbol, articles)
+				if err != nil {
+					return len(archived), err
+				}
+				return len(archived), saveProviderSentiment(ctx, symbol, archived)
+			})
+		},
+	})
+
+	add(cfg.SentimentSchedule, scheduler.Job{
+		Name:        "sentiment",
+		Description: "EODHD and mapped feed news + FinBERT -> sentiment_analysis, aggregate_sentiment",
+		Timeout:     30 * time.Minute,
+		Run: func(ctx context.Context, report *scheduler.Report) error {
+			if !BertInference.IsInitialized() {
+				return fmt.Errorf("BERT model not initialized")
+			}
+			return forEachSymbol(ctx, cfg, report, func(ctx context.Context, symbol string) (int, error) {
+				return ingestSentiment(ctx, news, symbol)
+			})
+		},
+	})
+
+	return jobs
+}
+
+// ingestSentiment scores the latest news for a symbol, including the feeds
+// mapped to it, stores articles not seen before with one sentiment row per
+// linked ticker, and refreshes the symbol's aggregate.
+func ingestSentiment(ctx context.Context, news *NewsClient.NewsClient, symbol string) (int, error) {
+	items, err := sentAnalysis.FetchSymbolNews(ctx, news, symbol)
+	if err != nil {
+		return 0, err
+	}
+	sentAnalysis.AnalyzeItems(ctx, items)
+	shadow.Submit(shadow.FromNewsItems(symbol, items))
+
+	var sentiments []BertInference.BERTSentiment
+	stored := 0
+	for i := range items {
+		item := &items[i]
+		if item.BERTSentiment.Label == "" {
+			continue
+		}
+		sentiments = append(sentiments, item.BERTSentiment)
+
+		if item.Link != "" {
+			exists, err := database.NewsLinkExists(ctx, symbol, item.Link)
+			if err != nil {
+				return stored, err
+			}
+			if exists {
+				continue
+			}
+		}
+
+		newsID, err := database.SaveNewsWithSentiment(ctx, symbol, item)
+		if err != nil {
+			return stored, err
+		}
+		for _, ticker := range SymbolLinker.WithSymbol(symbol, item.Symbols) {
+			if err := database.SaveNewsSentiment(ctx, newsID, ticker, item); err != nil {
+				return stored, err
+			}
+		}
+		stored++
+	}
+
+	if len(sentiments) == 0 {
+		return stored, nil
+	}
+	aggregate := sentAnalysis.AggregateSentiments(sentiments)
+	aggregate.Symbol = symbol
+	aggregate.NewsCount = len(items)
+	return stored, database.SaveAggregatedSentiment(ctx, symbol, aggregate)
+}
+
+// saveProviderSentiment scores newly archived articles that carry the
+// provider's own sentiment with FinBERT and stores both, once per linked
+// ticker, so the model agreement report covers the news job too
+func saveProviderSentiment(ctx context.Context, symbol string, articles []NewsClient.NewsArticle) error {
+	var rated []NewsClient.NewsArticle
+	for _, article := range articles {
+		if article.ProviderSentiment != nil {
+			rated = append(rated, article)
+		}
+	}
+	if len(rated) == 0 || !BertInference.IsInitialized() {
+		return nil
+	}
+
+	sentiments, err := sentAnalysis.AnalyzeArticles(ctx, rated)
+	if err != nil {
+		return fmt.Errorf("failed to score provider-rated articles: %w", err)
+	}
+	for i, sentiment := range sentiments {
+		for _, ticker := range SymbolLinker.WithSymbol(symbol, rated[i].Symbols) {
+			if err := database.SaveArticleSentiment(ctx, ticker, &rated[i], &sentiment.BERTSentiment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// forEachSymbol runs fn for every symbol in the universe on a worker pool,
+// recording the item count or failure of each symbol in the report.
+func forEachSymbol(ctx context.Context, cfg *Config, report *scheduler.Report, fn func(ctx context.Context, symbol string) (int, error)) error {
+	tasks := make([]workerpool.Task, len(cfg.Symbols))
+	for i, symbol := range cfg.Symbols {
+		tasks[i] = func(ctx context.Context) error {
+			n, err := fn(ctx, symbol)
+			if err != nil {
+				report.Fail(symbol, err)
+				return err
+			}
+			report.Processed(n)
+			return nil
+		}
+	}
+
+	workerpool.Run(ctx, cfg.Workers, tasks)
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("run cancelled: %w", ctx.Err())
+	}
+	return nil
+}
@@ -0,0 +1,637 @@
+// Package jobs runs slow analyses in the background. Submitting a job
+// returns at once with its ID; a fixed set of workers runs queued jobs.
+// A job's status and progress are kept in memory, and every state change
+// is written to Redis and Postgres so the status and result can still be
+// read after a restart until the job expires.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/internal/cache"
+	"github.com/MadebyDaris/dogonomics/internal/database"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// keyPrefix namespaces job records in Redis
+const keyPrefix = "job:"
+
+// Job states
+const (
+	StateQueued    = "queued"
+	StateRunning   = "running"
+	StateCompleted = "completed"
+	StateFailed    = "failed"
+	StateCancelled = "cancelled"
+)
+
+var (
+	// ErrNotFound is returned for unknown or expired jobs
+	ErrNotFound = errors.New("job not found")
+	// ErrNotFinished is returned when a result is requested before the job ends
+	ErrNotFinished = errors.New("job not finished")
+	// ErrQueueFull is returned when too many jobs are waiting
+	ErrQueueFull = errors.New("job queue is full, try again later")
+	// ErrNotStarted is returned when Submit is called before Start
+	ErrNotStarted = errors.New("job workers not started")
+	// ErrNotLocal is returned when cancelling a job this process is not running
+	ErrNotLocal = errors.New("job is not running in this process")
+
+	errCancelled = errors.New("cancelled by request")
+	errShutdown  = errors.New("interrupted by shutdown")
+)
+
+// Config controls the job workers
+type Config struct {
+	// Workers is how many jobs run at once
+	Workers int
+	// QueueSize is how many jobs may wait; more are rejected
+	QueueSize int
+	// Timeout bounds how long one job may run
+	Timeout time.Duration
+	// Retention is how long finished jobs and their results are kept
+	Retention time.Duration
+	// Instance identifies this process's jobs in Postgres. It should stay
+	// the same across restarts of one replica and differ between replicas.
+	Instance string
+	// Heartbeat is how often this process marks its unfinished jobs alive.
+	// Other replicas abandon jobs whose heartbeat is three intervals old.
+	Heartbeat time.Duration
+}
+
+// LoadConfigFromEnv loads job configuration from environment variables
+func LoadConfigFromEnv() *Config {
+	cfg := &Config{Workers: 2, QueueSize: 100, Timeout: 30 * time.Minute, Retention: 24 * time.Hour, Heartbeat: 30 * time.Second}
+	if n, err := strconv.Atoi(os.Getenv("JOB_WORKERS")); err == nil && n > 0 {
+		cfg.Workers = n
+	}
+	if n, err := strconv.Atoi(os.Getenv("JOB_QUEUE_SIZE")); err == nil && n > 0 {
+		cfg.QueueSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("JOB_TIMEOUT")); err == nil && d > 0 {
+		cfg.Timeout = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("JOB_RETENTION")); err == nil && d > 0 {
+		cfg.Retention = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("JOB_HEARTBEAT")); err == nil && d > 0 {
+		cfg.Heartbeat = d
+	}
+	cfg.Instance = os.Getenv("JOB_INSTANCE_ID")
+	if cfg.Instance == "" {
+		cfg.Instance, _ = os.Hostname()
+	}
+	if cfg.Instance == "" {
+		cfg.Instance = uuid.NewString()
+	}
+	return cfg
+}
+
+var (
+	jobsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "analysis_jobs_total",
+			Help: "Finished asynchronous jobs by kind and final state",
+		},
+		[]string{"kind", "state"},
+	)
+
+	jobsQueued = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "analysis_jobs_queued",
+			Help: "Asynchronous jobs waiting for a worker",
+		},
+	)
+
+	jobDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "analysis_job_duration_seconds",
+			Help:    "Run time of asynchronous jobs by kind",
+			Buckets: []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800},
+		},
+		[]string{"kind"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(jobsTotal, jobsQueued, jobDuration)
+}
+
+// Func does the work of a job. It should stop when ctx is done and may
+// report progress. A result returned with an error is kept as a partial
+// result.
+type Func func(ctx context.Context, progress *Progress) (any, error)
+
+// Status is a snapshot of a job
+type Status struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	State      string          `json:"status"`
+	Params     json.RawMessage `json:"params,omitempty"`
+	Total      int             `json:"total"`
+	Processed  int             `json:"processed"`
+	Failed     int             `json:"failed"`
+	Progress   float64         `json:"progress"` // 0.0 to 1.0
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"`
+}
+
+// Done reports whether the job has reached a final state
+func (s Status) Done() bool {
+	return s.State == StateCompleted || s.State == StateFailed || s.State == StateCancelled
+}
+
+// record is what Redis keeps for a job
+type record struct {
+	Status Status          `json:"status"`
+	Result json.RawMessage `json:"result,omitempty"`
+}
+
+// job is a job run by this process
+type job struct {
+	mutex   sync.Mutex
+	status  Status
+	result  json.RawMessage
+	run     Func
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	changed chan struct{}
+}
+
+// Progress reports how far a job has got
+type Progress struct {
+	job *job
+}
+
+// SetTotal sets how many items the job will process
+func (p *Progress) SetTotal(n int) {
+	p.job.update(func(s *Status) { s.Total = n })
+}
+
+// Add counts processed items, failed ones included
+func (p *Progress) Add(processed, failed int) {
+	p.job.update(func(s *Status) {
+		s.Processed += processed
+		s.Failed += failed
+	})
+}
+
+func (j *job) snapshot() (Status, json.RawMessage) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.status, j.result
+}
+
+// update applies fn to the status and wakes watchers
+func (j *job) update(fn func(s *Status)) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	fn(&j.status)
+	if j.status.Total > 0 {
+		j.status.Progress = float64(j.status.Processed) / float64(j.status.Total)
+	}
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+var (
+	mutex   sync.Mutex
+	config  *Config
+	jobs    map[string]*job
+	queue   chan *job
+	stopped chan struct{}
+	workers sync.WaitGroup
+)
+
+// Start starts the workers and the janitor that removes expired jobs. Jobs
+// a previous process of this instance left queued or running, and those
+// of other instances that stopped sending heartbeats, are marked failed.
+func Start(cfg *Config) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if jobs != nil {
+		return
+	}
+	config = cfg
+	jobs = make(map[string]*job)
+	queue = make(chan *job, cfg.QueueSize)
+	stopped = make(chan struct{})
+
+	abandon(cfg.Instance, cfg)
+
+	for i := 0; i < cfg.Workers; i++ {
+		workers.Add(1)
+		go worker(queue, cfg)
+	}
+	go janitor(stopped, cfg)
+}
+
+// abandon marks jobs owned by owner, or with a stale heartbeat, as failed
+// and removes them from Redis
+func abandon(owner string, cfg *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ids, err := database.AbandonAnalysisJobs(ctx, owner, time.Now().Add(-3*cfg.Heartbeat))
+	if err != nil {
+		if !errors.Is(err, database.ErrDatabaseNotConnected) {
+			log.Printf("WARNING: Failed to clean up interrupted analysis jobs: %v", err)
+		}
+		return
+	}
+	for _, id := range ids {
+		if err := cache.Delete(ctx, keyPrefix+id.String()); err != nil {
+			log.Printf("Failed to remove interrupted job %s from Redis: %v", id, err)
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("Marked %d interrupted analysis jobs as failed", len(ids))
+	}
+}
+
+// Stop cancels queued and running jobs, records them as interrupted and
+// waits for the workers to finish
+func Stop() {
+	mutex.Lock()
+	if jobs == nil {
+		mutex.Unlock()
+		return
+	}
+	for _, j := range jobs {
+		j.cancel(errShutdown)
+	}
+	close(queue)
+	close(stopped)
+	jobs = nil
+	mutex.Unlock()
+	workers.Wait()
+}
+
+// Submit queues a job of the given kind. params describes the request and
+// is stored with the job; run does the work.
+func Submit(kind string, params any, run Func) (Status, error) {
+	var rawParams json.RawMessage
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return Status{}, fmt.Errorf("invalid job parameters: %v", err)
+		}
+		rawParams = data
+	}
+
+	mutex.Lock()
+	if jobs == nil {
+		mutex.Unlock()
+		return Status{}, ErrNotStarted
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	j := &job{
+		status: Status{
+			ID:        uuid.NewString(),
+			Kind:      kind,
+			State:     StateQueued,
+			Params:    rawParams,
+			CreatedAt: time.Now(),
+		},
+		run:     run,
+		ctx:     ctx,
+		cancel:  cancel,
+		changed: make(chan struct{}),
+	}
+	select {
+	case queue <- j:
+	default:
+		mutex.Unlock()
+		cancel(nil)
+		return Status{}, ErrQueueFull
+	}
+	jobs[j.status.ID] = j
+	jobsQueued.Inc()
+	cfg := config
+	mutex.Unlock()
+
+	status, _ := j.snapshot()
+	persist(status, nil, cfg)
+	return status, nil
+}
+
+// Get returns a job's status from this process, Redis or Postgres
+func Get(ctx context.Context, id string) (Status, error) {
+	status, _, err := lookup(ctx, id, false)
+	return status, err
+}
+
+// Result returns a finished job's status and result. The result is null
+// for jobs that failed without one.
+func Result(ctx context.Context, id string) (Status, json.RawMessage, error) {
+	status, result, err := lookup(ctx, id, true)
+	if err != nil {
+		return status, nil, err
+	}
+	if !status.Done() {
+		return status, nil, ErrNotFinished
+	}
+	return status, result, nil
+}
+
+// Watch returns a channel that is closed on the job's next change, or nil
+// when this process is not running the job
+func Watch(id string) <-chan struct{} {
+	mutex.Lock()
+	j := jobs[id]
+	mutex.Unlock()
+	if j == nil {
+		return nil
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if j.status.Done() {
+		return nil
+	}
+	return j.changed
+}
+
+// Cancel stops a queued or running job. Finished jobs are left as they are.
+func Cancel(ctx context.Context, id string) (Status, error) {
+	mutex.Lock()
+	j := jobs[id]
+	mutex.Unlock()
+	if j == nil {
+		status, err := Get(ctx, id)
+		if err == nil && !status.Done() {
+			return status, ErrNotLocal
+		}
+		return status, err
+	}
+	j.cancel(errCancelled)
+
+	// A queued job is finished here rather than when a worker reaches it
+	j.mutex.Lock()
+	queued := j.status.State == StateQueued
+	if queued {
+		j.status.State = StateCancelled // claim it so the worker skips it
+	}
+	j.mutex.Unlock()
+	if queued {
+		mutex.Lock()
+		cfg := config
+		mutex.Unlock()
+		finish(j, cfg, nil, context.Canceled, errCancelled)
+	}
+	status, _ := j.snapshot()
+	return status, nil
+}
+
+func lookup(ctx context.Context, id string, withResult bool) (Status, json.RawMessage, error) {
+	mutex.Lock()
+	j := jobs[id]
+	mutex.Unlock()
+	if j != nil {
+		status, result := j.snapshot()
+		return status, result, nil
+	}
+
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return Status{}, nil, ErrNotFound
+	}
+
+	if data, err := cache.Get(ctx, keyPrefix+id); err != nil {
+		log.Printf("Failed to read job %s from Redis: %v", id, err)
+	} else if data != "" {
+		var rec record
+		if err := json.Unmarshal([]byte(data), &rec); err == nil {
+			return rec.Status, rec.Result, nil
+		}
+	}
+
+	stored, err := database.GetAnalysisJob(ctx, parsed)
+	if err != nil && !errors.Is(err, database.ErrDatabaseNotConnected) {
+		return Status{}, nil, err
+	}
+	if stored == nil {
+		return Status{}, nil, ErrNotFound
+	}
+	status := fromStored(stored)
+	if status.ExpiresAt != nil && time.Now().After(*status.ExpiresAt) {
+		return Status{}, nil, ErrNotFound
+	}
+	return status, stored.Result, nil
+}
+
+func worker(queue chan *job, cfg *Config) {
+	defer workers.Done()
+	for j := range queue {
+		jobsQueued.Dec()
+		execute(j, cfg)
+	}
+}
+
+// execute runs one job and records its outcome
+func execute(j *job, cfg *Config) {
+	started := time.Now()
+	claimed := false
+	j.update(func(s *Status) {
+		if s.State == StateQueued {
+			s.State, s.StartedAt, claimed = StateRunning, &started, true
+		}
+	})
+	if !claimed {
+		return // cancelled while queued
+	}
+
+	ctx, cancel := context.WithTimeoutCause(j.ctx, cfg.Timeout, fmt.Errorf("timed out after %s", cfg.Timeout))
+	defer cancel()
+	if ctx.Err() != nil {
+		finish(j, cfg, nil, ctx.Err(), context.Cause(ctx))
+		return
+	}
+
+	status, _ := j.snapshot()
+	persist(status, nil, cfg)
+
+	result, err := run(ctx, j)
+	jobDuration.WithLabelValues(status.Kind).Observe(time.Since(started).Seconds())
+
+	var data json.RawMessage
+	if result != nil {
+		encoded, marshalErr := json.Marshal(result)
+		if marshalErr != nil && err == nil {
+			err = fmt.Errorf("failed to encode result: %v", marshalErr)
+		}
+		data = encoded
+	}
+	var cause error
+	if err != nil {
+		cause = context.Cause(ctx)
+	}
+	finish(j, cfg, data, err, cause)
+}
+
+// run calls the job's function, turning a panic into an error
+func run(ctx context.Context, j *job) (result any, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return j.run(ctx, &Progress{job: j})
+}
+
+// finish records a job's final state. cause is why its context ended, if
+// it did: a cancellation or shutdown cancels the job, a timeout fails it.
+func finish(j *job, cfg *Config, result json.RawMessage, err, cause error) {
+	now := time.Now()
+	expires := now.Add(cfg.Retention)
+	j.mutex.Lock()
+	j.result = result
+	j.mutex.Unlock()
+	j.update(func(s *Status) {
+		switch {
+		case err == nil:
+			s.State = StateCompleted
+			if s.Total == 0 {
+				s.Progress = 1
+			}
+		case errors.Is(cause, errCancelled) || errors.Is(cause, errShutdown):
+			s.State = StateCancelled
+			s.Error = cause.Error()
+		case cause != nil:
+			s.State = StateFailed
+			s.Error = cause.Error()
+		default:
+			s.State = StateFailed
+			s.Error = err.Error()
+		}
+		s.FinishedAt = &now
+		s.ExpiresAt = &expires
+	})
+
+	status, _ := j.snapshot()
+	jobsTotal.WithLabelValues(status.Kind, status.State).Inc()
+	persist(status, result, cfg)
+	log.Printf("Job %s (%s) %s after %s", status.ID, status.Kind, status.State, now.Sub(status.CreatedAt).Round(time.Millisecond))
+}
+
+// persist writes a job to Redis and Postgres. Redis entries live until the
+// job expires; unfinished ones for at most the job timeout plus retention.
+func persist(status Status, result json.RawMessage, cfg *Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ttl := cfg.Timeout + cfg.Retention
+	if status.ExpiresAt != nil {
+		ttl = time.Until(*status.ExpiresAt)
+	}
+	if data, err := json.Marshal(record{Status: status, Result: result}); err == nil {
+		if err := cache.Set(ctx, keyPrefix+status.ID, string(data), ttl); err != nil {
+			log.Printf("Failed to store job %s in Redis: %v", status.ID, err)
+		}
+	}
+
+	if err := database.SaveAnalysisJob(ctx, toStored(status, result, cfg)); err != nil && !errors.Is(err, database.ErrDatabaseNotConnected) {
+		log.Printf("Failed to store job %s: %v", status.ID, err)
+	}
+}
+
+func toStored(status Status, result json.RawMessage, cfg *Config) *database.AnalysisJob {
+	stored := &database.AnalysisJob{
+		ID:         uuid.MustParse(status.ID),
+		Kind:       status.Kind,
+		Status:     status.State,
+		Params:     status.Params,
+		Total:      status.Total,
+		Processed:  status.Processed,
+		Failed:     status.Failed,
+		Result:     result,
+		CreatedAt:  status.CreatedAt,
+		StartedAt:  status.StartedAt,
+		FinishedAt: status.FinishedAt,
+		Owner:      cfg.Instance,
+	}
+	if status.Error != "" {
+		stored.ErrorMessage = &status.Error
+	}
+	return stored
+}
+
+func fromStored(stored *database.AnalysisJob) Status {
+	status := Status{
+		ID:         stored.ID.String(),
+		Kind:       stored.Kind,
+		State:      stored.Status,
+		Params:     stored.Params,
+		Total:      stored.Total,
+		Processed:  stored.Processed,
+		Failed:     stored.Failed,
+		CreatedAt:  stored.CreatedAt,
+		StartedAt:  stored.StartedAt,
+		FinishedAt: stored.FinishedAt,
+	}
+	if stored.ErrorMessage != nil {
+		status.Error = *stored.ErrorMessage
+	}
+	switch {
+	case status.Total > 0:
+		status.Progress = float64(status.Processed) / float64(status.Total)
+	case status.State == StateCompleted:
+		status.Progress = 1
+	}
+	mutex.Lock()
+	cfg := config
+	mutex.Unlock()
+	if status.FinishedAt != nil && cfg != nil {
+		expires := status.FinishedAt.Add(cfg.Retention)
+		status.ExpiresAt = &expires
+	}
+	return status
+}
+
+// janitor removes finished jobs from memory and Postgres once their
+// retention has passed. It also sends this process's heartbeat and fails
+// jobs of instances whose heartbeat has stopped.
+func janitor(stopped chan struct{}, cfg *Config) {
+	ticker := time.NewTicker(min(cfg.Retention, time.Minute))
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(cfg.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-stopped:
+			return
+		case <-heartbeat.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := database.HeartbeatAnalysisJobs(ctx, cfg.Instance); err != nil && !errors.Is(err, database.ErrDatabaseNotConnected) {
+				log.Printf("Failed to record analysis job heartbeat: %v", err)
+			}
+			cancel()
+		case now := <-ticker.C:
+			mutex.Lock()
+			for id, j := range jobs {
+				if status, _ := j.snapshot(); status.ExpiresAt != nil && now.After(*status.ExpiresAt) {
+					delete(jobs, id)
+				}
+			}
+			mutex.Unlock()
+
+			abandon("", cfg)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if _, err := database.DeleteAnalysisJobsBefore(ctx, now.Add(-cfg.Retention)); err != nil && !errors.Is(err, database.ErrDatabaseNotConnected) {
+				log.Printf("Failed to remove expired analysis jobs: %v", err)
+			}
+			cancel()
+		}
+	}
+}
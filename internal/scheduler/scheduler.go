@@ -0,0 +1,322 @@
+// Package scheduler runs named background jobs on cron-like schedules and
+// records every run (and its per-item failures) in the job_runs table.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/internal/database"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownJob is returned when a job name is not registered
+	ErrUnknownJob = errors.New("unknown job")
+	// ErrJobRunning is returned when a job is triggered while already running
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// Run statuses stored in job_runs
+const (
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusPartial   = "partial" // finished, but some items failed
+	StatusFailed    = "failed"
+)
+
+// Report collects what a job run did. It is safe for concurrent use so a
+// job can fan work out to a worker pool.
+type Report struct {
+	mu        sync.Mutex
+	processed int
+	failures  []string
+}
+
+// Processed records n successfully handled items
+func (r *Report) Processed(n int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.processed += n
+}
+
+// Fail records a failed item without aborting the run
+func (r *Report) Fail(item string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = append(r.failures, fmt.Sprintf("%s: %v", item, err))
+}
+
+// Func is the body of a job. Returning an error marks the whole run as
+// failed; item-level problems should go through Report.Fail instead.
+type Func func(ctx context.Context, report *Report) error
+
+// Job is a named unit of scheduled work
+type Job struct {
+	Name        string
+	Spec        string // see ParseSchedule
+	Description string
+	Timeout     time.Duration // per run; defaults to 10 minutes
+	Run         Func
+}
+
+// JobStatus is a snapshot of a registered job
+type JobStatus struct {
+	Name        string           `json:"name"`
+	Schedule    string           `json:"schedule"`
+	Description string           `json:"description,omitempty"`
+	Running     bool             `json:"running"`
+	NextRun     *time.Time       `json:"next_run,omitempty"`
+	LastRun     *database.JobRun `json:"last_run,omitempty"`
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	running  bool
+	nextRun  time.Time
+	lastRun  *database.JobRun
+}
+
+// Scheduler owns a set of jobs and their timers
+type Scheduler struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	started bool
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		entries: make(map[string]*entry),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return fmt.Errorf("job needs a name and a run function")
+	}
+	schedule, err := ParseSchedule(job.Spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = 10 * time.Minute
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return fmt.Errorf("job %s: cannot add jobs after Start", job.Name)
+	}
+	if _, exists := s.entries[job.Name]; exists {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	s.entries[job.Name] = &entry{job: job, schedule: schedule}
+	return nil
+}
+
+// Start launches one timer goroutine per job
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go s.loop(e)
+	}
+}
+
+// Stop cancels in-flight runs and waits for them to be recorded
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(e *entry) {
+	defer s.wg.Done()
+
+	for {
+		next := e.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Job %s has no future activation, not scheduling", e.job.Name)
+			return
+		}
+		s.mu.Lock()
+		e.nextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if !s.claim(e) {
+			log.Printf("Job %s still running, skipping scheduled run", e.job.Name)
+			continue
+		}
+		s.execute(e, "schedule")
+	}
+}
+
+// RunNow starts a job immediately in the background
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	e, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+	if !s.claim(e) {
+		return ErrJobRunning
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(e, "manual")
+	}()
+	return nil
+}
+
+// claim marks the job as running, or reports false if it already is
+func (s *Scheduler) claim(e *entry) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.running {
+		return false
+	}
+	e.running = true
+	return true
+}
+
+func (s *Scheduler) execute(e *entry, trigger string) {
+	run := &database.JobRun{
+		JobName:   e.job.Name,
+		Trigger:   trigger,
+		Status:    StatusRunning,
+		StartedAt: time.Now(),
+	}
+
+	dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	id, err := database.StartJobRun(dbCtx, run)
+	cancel()
+	if err != nil && !errors.Is(err, database.ErrDatabaseNotConnected) {
+		log.Printf("Failed to record start of job %s: %v", e.job.Name, err)
+	}
+	run.ID = id
+
+	report := &Report{}
+	ctx, cancelRun := context.WithTimeout(s.ctx, e.job.Timeout)
+	runErr := safeRun(ctx, e.job.Run, report)
+	cancelRun()
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.ItemsProcessed = report.processed
+	run.ItemsFailed = len(report.failures)
+	run.Failures = report.failures
+
+	switch {
+	case runErr != nil:
+		run.Status = StatusFailed
+		msg := runErr.Error()
+		run.ErrorMessage = &msg
+	case run.ItemsFailed > 0 && run.ItemsProcessed == 0:
+		run.Status = StatusFailed
+	case run.ItemsFailed > 0:
+		run.Status = StatusPartial
+	default:
+		run.Status = StatusSucceeded
+	}
+
+	log.Printf("Job %s (%s) %s in %s: %d processed, %d failed",
+		e.job.Name, trigger, run.Status, finished.Sub(run.StartedAt).Round(time.Millisecond),
+		run.ItemsProcessed, run.ItemsFailed)
+
+	if run.ID != uuid.Nil {
+		dbCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := database.FinishJobRun(dbCtx, run); err != nil {
+			log.Printf("Failed to record result of job %s: %v", e.job.Name, err)
+		}
+		cancel()
+	}
+
+	s.mu.Lock()
+	e.running = false
+	e.lastRun = run
+	s.mu.Unlock()
+}
+
+// safeRun keeps a panicking job from taking the scheduler down with it
+func safeRun(ctx context.Context, fn Func, report *Report) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx, report)
+}
+
+// Status returns a snapshot of every job, sorted by name
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]JobStatus, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e.status())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// JobStatus returns a snapshot of a single job
+func (s *Scheduler) JobStatus(name string) (JobStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[name]
+	if !ok {
+		return JobStatus{}, ErrUnknownJob
+	}
+	return e.status(), nil
+}
+
+// status must be called with the scheduler lock held
+func (e *entry) status() JobStatus {
+	st := JobStatus{
+		Name:        e.job.Name,
+		Schedule:    e.job.Spec,
+		Description: e.job.Description,
+		Running:     e.running,
+	}
+	if !e.nextRun.IsZero() {
+		next := e.nextRun
+		st.NextRun = &next
+	}
+	if e.lastRun != nil {
+		last := *e.lastRun
+		st.LastRun = &last
+	}
+	return st
+}
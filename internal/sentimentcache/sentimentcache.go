@@ -0,0 +1,235 @@
+// Package sentimentcache caches model results by content. A hash of the
+// normalized document text plus the model's name@version and fingerprint
+// maps to its DocumentSentiment, held in a bounded in-process LRU in front
+// of Redis, so the same headline is scored once no matter which endpoint or
+// job sees it.
+package sentimentcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/MadebyDaris/dogonomics/internal/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/text/unicode/norm"
+)
+
+// keyPrefix namespaces inference results in Redis
+const keyPrefix = "inference:"
+
+// Config controls the inference cache
+type Config struct {
+	// LRUSize is how many results are kept in process; 0 disables the LRU
+	LRUSize int
+	// TTL is how long results live in Redis
+	TTL time.Duration
+}
+
+// LoadConfigFromEnv loads inference cache configuration from environment
+// variables
+func LoadConfigFromEnv() *Config {
+	cfg := &Config{LRUSize: 10000, TTL: 7 * 24 * time.Hour}
+	if n, err := strconv.Atoi(os.Getenv("INFERENCE_CACHE_SIZE")); err == nil && n >= 0 {
+		cfg.LRUSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("INFERENCE_CACHE_TTL")); err == nil && d > 0 {
+		cfg.TTL = d
+	}
+	return cfg
+}
+
+var (
+	lookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "inference_cache_lookups_total",
+			Help: "Inference cache lookups by result (lru_hit, redis_hit, miss)",
+		},
+		[]string{"result"},
+	)
+
+	lruEntries = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "inference_cache_lru_entries",
+			Help: "Results held in the in-process inference cache",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(lookupsTotal, lruEntries)
+}
+
+var (
+	mutex   sync.RWMutex
+	enabled bool
+	config  *Config
+	lru     *cache.LRU[string, BertInference.DocumentSentiment]
+)
+
+// Init enables the cache. Redis is used when internal/cache is connected.
+// Until Init is called, RunDocuments passes straight through to the model.
+func Init(cfg *Config) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	enabled, config, lru = true, cfg, nil
+	if cfg.LRUSize > 0 {
+		lru = cache.NewLRU[string, BertInference.DocumentSentiment](cfg.LRUSize)
+	}
+	lruEntries.Set(0)
+}
+
+// Purge empties the in-process cache. Redis entries expire with their TTL.
+func Purge() {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if lru != nil {
+		lru.Purge()
+		lruEntries.Set(0)
+	}
+}
+
+// RunDocuments scores documents with the named model ("" for the default)
+// like BertInference.RunModelDocuments, answering from the cache where it
+// can and running only the misses through the model
+func RunDocuments(ctx context.Context, model string, docs []BertInference.Document) ([]*BertInference.DocumentSentiment, error) {
+	mutex.RLock()
+	on, cfg, l := enabled, config, lru
+	mutex.RUnlock()
+	if !on || len(docs) == 0 {
+		return BertInference.RunModelDocuments(model, docs)
+	}
+
+	id := modelID(model)
+	if id == "" {
+		return BertInference.RunModelDocuments(model, docs)
+	}
+
+	results := make([]*BertInference.DocumentSentiment, len(docs))
+	keys := make([]string, len(docs))
+	for i, doc := range docs {
+		keys[i] = Key(id, doc)
+	}
+
+	// In-process first, then one Redis round trip for the rest
+	var remote []int
+	for i, key := range keys {
+		if l != nil {
+			if cached, ok := l.Get(key); ok {
+				results[i] = clone(cached)
+				lookupsTotal.WithLabelValues("lru_hit").Inc()
+				continue
+			}
+		}
+		remote = append(remote, i)
+	}
+
+	var misses []int
+	if len(remote) > 0 {
+		remoteKeys := make([]string, len(remote))
+		for j, i := range remote {
+			remoteKeys[j] = keyPrefix + keys[i]
+		}
+		values, err := cache.GetMany(ctx, remoteKeys)
+		if err != nil {
+			log.Printf("Inference cache Redis lookup failed: %v", err)
+		}
+		for j, i := range remote {
+			var cached BertInference.DocumentSentiment
+			if values[j] == "" || json.Unmarshal([]byte(values[j]), &cached) != nil {
+				misses = append(misses, i)
+				continue
+			}
+			results[i] = clone(cached)
+			if l != nil {
+				l.Add(keys[i], cached)
+			}
+			lookupsTotal.WithLabelValues("redis_hit").Inc()
+		}
+	}
+	lookupsTotal.WithLabelValues("miss").Add(float64(len(misses)))
+
+	if len(misses) > 0 {
+		missDocs := make([]BertInference.Document, len(misses))
+		for j, i := range misses {
+			missDocs[j] = docs[i]
+		}
+		scored, err := BertInference.RunModelDocuments(model, missDocs)
+		if err != nil {
+			return nil, err
+		}
+
+		// A reload during the call may have answered with another model;
+		// its results are returned but not cached under this model's keys
+		cacheable := modelID(model) == id
+
+		store := make(map[string]string, len(misses))
+		for j, i := range misses {
+			results[i] = scored[j]
+			if !cacheable {
+				continue
+			}
+			key := keys[i]
+			if l != nil {
+				l.Add(key, *clone(*scored[j]))
+			}
+			if data, err := json.Marshal(scored[j]); err == nil {
+				store[keyPrefix+key] = string(data)
+			}
+		}
+		if err := cache.SetMany(ctx, store, cfg.TTL); err != nil {
+			log.Printf("Inference cache Redis store failed: %v", err)
+		}
+	}
+
+	if l != nil {
+		lruEntries.Set(float64(l.Len()))
+	}
+	return results, nil
+}
+
+// Key is the content address of a document scored by a model: a SHA-256 of
+// the model's identity (see modelID) and the normalized headline and body
+func Key(modelID string, doc BertInference.Document) string {
+	h := sha256.New()
+	h.Write([]byte(modelID))
+	h.Write([]byte{0})
+	h.Write([]byte(normalize(doc.Title)))
+	h.Write([]byte{0})
+	h.Write([]byte(normalize(doc.Body)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalize applies NFC and collapses whitespace, which the tokenizer
+// ignores anyway
+func normalize(text string) string {
+	return strings.Join(strings.Fields(norm.NFC.String(text)), " ")
+}
+
+// modelID resolves a model name ("" for the default) to its name@version
+// and fingerprint. New weights or calibration reloaded under the same
+// name@version change the fingerprint, so results cached for the old files
+// are never served for the new ones.
+func modelID(name string) string {
+	for _, m := range BertInference.Models() {
+		if (name == "" && m.Default) || m.Name == name {
+			return m.ID + "#" + m.Fingerprint
+		}
+	}
+	return ""
+}
+
+// clone copies a result so callers cannot modify the cached chunks
+func clone(s BertInference.DocumentSentiment) *BertInference.DocumentSentiment {
+	s.Chunks = slices.Clone(s.Chunks)
+	return &s
+}
@@ -0,0 +1,73 @@
+package sentimentcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lookups reads inference_cache_lookups_total for one result
+func lookups(t *testing.T, result string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "inference_cache_lookups_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "result" && label.GetValue() == result {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestRunDocumentsHitsLRU(t *testing.T) {
+	Init(&Config{LRUSize: 16})
+	t.Cleanup(func() {
+		mutex.Lock()
+		enabled, config, lru = false, nil, nil
+		mutex.Unlock()
+	})
+
+	docs := []BertInference.Document{{Title: "Acme beats estimates", Body: "Profit rose sharply on strong demand."}}
+	ctx := context.Background()
+
+	first, err := RunDocuments(ctx, "", docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits := lookups(t, "lru_hit")
+	misses := lookups(t, "miss")
+
+	second, err := RunDocuments(ctx, "", docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := lookups(t, "lru_hit") - hits; got != 1 {
+		t.Errorf("second call: %v lru hits, want 1", got)
+	}
+	if got := lookups(t, "miss") - misses; got != 0 {
+		t.Errorf("second call: %v misses, want 0", got)
+	}
+	if first[0].Label != second[0].Label || first[0].Score != second[0].Score || first[0].Model != second[0].Model {
+		t.Errorf("cached result %+v differs from scored %+v", second[0].BERTSentiment, first[0].BERTSentiment)
+	}
+
+	// Whitespace the tokenizer ignores addresses the same entry
+	spaced := []BertInference.Document{{Title: "Acme  beats estimates ", Body: "Profit rose sharply\non strong demand."}}
+	if _, err := RunDocuments(ctx, "", spaced); err != nil {
+		t.Fatal(err)
+	}
+	if got := lookups(t, "lru_hit") - hits; got != 2 {
+		t.Errorf("normalized document: %v lru hits in total, want 2", got)
+	}
+}
@@ -0,0 +1,240 @@
+// Package shadow runs candidate sentiment models alongside the production
+// model. Articles scored in production are queued, re-scored by every
+// shadow model listed in the model manifest in the background, and stored
+// as production/candidate pairs in shadow_sentiment for comparison.
+package shadow
+
+import (
+	"context"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
+	"github.com/MadebyDaris/dogonomics/internal/database"
+	"github.com/MadebyDaris/dogonomics/internal/sentimentcache"
+	"github.com/MadebyDaris/dogonomics/sentAnalysis"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Item is one article as scored in production
+type Item struct {
+	Link       string
+	Symbols    []string
+	Document   BertInference.Document
+	Production BertInference.BERTSentiment
+}
+
+// Config controls how much production traffic is shadowed
+type Config struct {
+	// SampleRate is the fraction of articles re-scored, from 0 to 1
+	SampleRate float64
+	// QueueSize is how many submissions may wait; more are dropped
+	QueueSize int
+}
+
+// LoadConfigFromEnv loads shadow configuration from environment variables
+func LoadConfigFromEnv() *Config {
+	cfg := &Config{SampleRate: 1, QueueSize: 16}
+	if rate, err := strconv.ParseFloat(os.Getenv("SHADOW_SAMPLE_RATE"), 64); err == nil && rate >= 0 && rate <= 1 {
+		cfg.SampleRate = rate
+	}
+	if n, err := strconv.Atoi(os.Getenv("SHADOW_QUEUE_SIZE")); err == nil && n > 0 {
+		cfg.QueueSize = n
+	}
+	return cfg
+}
+
+var (
+	evaluationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "shadow_evaluations_total",
+			Help: "Article/ticker pairs scored by a shadow model, by candidate and whether it agreed with production",
+		},
+		[]string{"candidate", "agreed"},
+	)
+
+	droppedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "shadow_dropped_total",
+			Help: "Shadow submissions dropped because the queue was full",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(evaluationsTotal, droppedTotal)
+}
+
+var (
+	mutex  sync.Mutex
+	config *Config
+	queue  chan []Item
+	done   chan struct{}
+)
+
+// Start begins processing submissions in the background
+func Start(cfg *Config) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if queue != nil {
+		return
+	}
+	config = cfg
+	queue = make(chan []Item, cfg.QueueSize)
+	done = make(chan struct{})
+	go worker(queue, done)
+}
+
+// Stop finishes queued submissions and stops the worker
+func Stop() {
+	mutex.Lock()
+	q, d := queue, done
+	queue = nil
+	mutex.Unlock()
+	if q == nil {
+		return
+	}
+	close(q)
+	<-d
+}
+
+// Submit queues production results for shadow scoring without blocking.
+// It does nothing when shadowing is not started or no shadow models are
+// loaded, and drops the submission when the queue is full.
+func Submit(items []Item) {
+	if len(items) == 0 || len(BertInference.ShadowModels()) == 0 {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if queue == nil {
+		return
+	}
+
+	sampled := items[:0:0]
+	for _, item := range items {
+		if item.Production.Label != "" && len(item.Symbols) > 0 && rand.Float64() < config.SampleRate {
+			sampled = append(sampled, item)
+		}
+	}
+	if len(sampled) == 0 {
+		return
+	}
+
+	select {
+	case queue <- sampled:
+	default:
+		droppedTotal.Inc()
+	}
+}
+
+// FromNewsItems builds shadow items from analysed news fetched for symbol,
+// attributing each article to symbol and every ticker it mentions
+func FromNewsItems(symbol string, newsItems []sentAnalysis.NewsItem) []Item {
+	items := make([]Item, 0, len(newsItems))
+	for _, news := range newsItems {
+		items = append(items, Item{
+			Link:       news.Link,
+			Symbols:    SymbolLinker.WithSymbol(symbol, news.Symbols),
+			Document:   sentAnalysis.NewsDocument(news.Title, news.Content),
+			Production: news.BERTSentiment,
+		})
+	}
+	return items
+}
+
+func worker(queue <-chan []Item, done chan<- struct{}) {
+	defer close(done)
+	for items := range queue {
+		for _, candidate := range BertInference.ShadowModels() {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			if err := evaluate(ctx, candidate, items); err != nil {
+				log.Printf("Shadow evaluation with %s failed: %v", candidate.ID, err)
+			}
+			cancel()
+		}
+	}
+}
+
+// evaluate scores the items with one candidate model and stores each
+// production/candidate pair per ticker. Articles the candidate has already
+// been compared on are skipped.
+func evaluate(ctx context.Context, candidate BertInference.ModelInfo, items []Item) error {
+	items, err := unseen(ctx, candidate, items)
+	if err != nil || len(items) == 0 {
+		return err
+	}
+
+	docs := make([]BertInference.Document, len(items))
+	for i, item := range items {
+		docs[i] = item.Document
+	}
+	results, err := sentimentcache.RunDocuments(ctx, candidate.Name, docs)
+	if err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		prod, cand := item.Production, results[i]
+		if prod.Model == cand.Model {
+			continue
+		}
+		var link *string
+		if item.Link != "" {
+			link = &item.Link
+		}
+		for _, symbol := range item.Symbols {
+			err := database.SaveShadowSentiment(ctx, &database.ShadowSentiment{
+				Symbol:               symbol,
+				Link:                 link,
+				ProductionModel:      prod.Model,
+				ProductionLabel:      prod.Label,
+				ProductionConfidence: prod.Confidence,
+				ProductionScore:      prod.Score,
+				CandidateModel:       cand.Model,
+				CandidateLabel:       cand.Label,
+				CandidateConfidence:  cand.Confidence,
+				CandidateScore:       cand.Score,
+			})
+			if err != nil {
+				return err
+			}
+			evaluationsTotal.WithLabelValues(cand.Model, strconv.FormatBool(prod.Label == cand.Label)).Inc()
+		}
+	}
+	return nil
+}
+
+// unseen drops items whose link the candidate has already been compared on
+// against the same production model
+func unseen(ctx context.Context, candidate BertInference.ModelInfo, items []Item) ([]Item, error) {
+	byProduction := make(map[string][]string)
+	for _, item := range items {
+		if item.Link != "" {
+			byProduction[item.Production.Model] = append(byProduction[item.Production.Model], item.Link)
+		}
+	}
+
+	seen := make(map[string]map[string]bool, len(byProduction))
+	for production, links := range byProduction {
+		s, err := database.ShadowedLinks(ctx, production, candidate.ID, links)
+		if err != nil {
+			return nil, err
+		}
+		seen[production] = s
+	}
+
+	out := items[:0:0]
+	for _, item := range items {
+		if item.Link == "" || !seen[item.Production.Model][item.Link] {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminAuth protects admin endpoints with the ADMIN_TOKEN bearer token.
+// When ADMIN_TOKEN is not set the endpoints are disabled.
+func AdminAuth() gin.HandlerFunc {
+	token := os.Getenv("ADMIN_TOKEN")
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "admin endpoints are disabled: set ADMIN_TOKEN to enable them",
+			})
+			return
+		}
+
+		given, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid or missing admin token",
+			})
+			return
+		}
+		c.Next()
+	}
+}
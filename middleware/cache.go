@@ -2,59 +2,140 @@ package middleware
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/MadebyDaris/dogonomics/internal/cache"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
 )
 
-// responseRecorder wraps gin.ResponseWriter so we can capture the response body
+// responseRecorder wraps gin.ResponseWriter and holds back the response
+// body, so validators can be added to the headers before it is sent
 type responseRecorder struct {
 	gin.ResponseWriter
 	body *bytes.Buffer
 }
 
 func (r *responseRecorder) Write(b []byte) (int, error) {
-	r.body.Write(b)
-	return r.ResponseWriter.Write(b)
-}
-
-// ttlOverrides maps path prefixes to cache durations.
-// More specific prefixes are checked first.
-var ttlOverrides = []struct {
-	prefix string
-	ttl    time.Duration
-}{
-	{"/quote/", 2 * time.Minute},
-	{"/ticker/", 5 * time.Minute},
-	{"/stock/", 5 * time.Minute},
-	{"/news/search", 5 * time.Minute},
-	{"/finnews/", 10 * time.Minute},
-	{"/news/general", 10 * time.Minute},
-	{"/news/symbol/", 10 * time.Minute},
-	{"/finnewsBert/", 15 * time.Minute},
-	{"/sentiment/", 15 * time.Minute},
-	{"/news/general/sentiment", 15 * time.Minute},
-	{"/chart/", 30 * time.Minute},
-	{"/commodities/", 30 * time.Minute},
-	{"/profile/", 1 * time.Hour},
-	{"/treasury/", 1 * time.Hour},
-}
-
-// skipPrefixes are paths that should never be cached
-var skipPrefixes = []string{
-	"/health",
-	"/metrics",
-	"/swagger/",
-	"/finbert/",
-}
-
-// CacheMiddleware returns Gin middleware that caches GET responses in Redis.
-// If Redis is unavailable, requests pass through un-cached.
-func CacheMiddleware(defaultTTL time.Duration) gin.HandlerFunc {
+	return r.body.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	return r.body.WriteString(s)
+}
+
+// refreshTimeout bounds a background refresh of a stale response
+const refreshTimeout = 2 * time.Minute
+
+// cacheEntry is a cached response. The Redis key outlives FreshUntil by
+// the stale window, so the response can still be served while it is
+// refreshed.
+type cacheEntry struct {
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       string      `json:"body"`
+	ETag       string      `json:"etag"`
+	Tags       []string    `json:"tags"`
+	StoredAt   time.Time   `json:"stored_at"`
+	FreshUntil time.Time   `json:"fresh_until"`
+}
+
+// CacheKeyPrefix namespaces cached responses in internal/cache
+const CacheKeyPrefix = "cache:"
+
+// defaultPrefix labels paths that match no rule of the cache policy
+const defaultPrefix = "default"
+
+// unstoredHeaders are set per response rather than replayed from the cache
+var unstoredHeaders = []string{
+	"X-Cache", "Etag", "Last-Modified", "Cache-Control", "Age", "Date",
+	"Content-Length", "Transfer-Encoding", "Connection", "Set-Cookie",
+}
+
+// refreshKey marks the context of a background refresh request
+type refreshKey struct{}
+
+// IsRefresh reports whether c is a background refresh of a stale cache
+// entry rather than a client request. Request logging and metrics skip
+// these.
+func IsRefresh(c *gin.Context) bool {
+	return c.Request.Context().Value(refreshKey{}) != nil
+}
+
+var (
+	cacheRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_cache_requests_total",
+			Help: "Cacheable GET requests by cache policy prefix and result (hit, stale, miss, coalesced)",
+		},
+		[]string{"prefix", "result"},
+	)
+
+	cacheEntrySize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_cache_entry_size_bytes",
+			Help:    "Size of cached response bodies by cache policy prefix",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+		},
+		[]string{"prefix"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRequestsTotal, cacheEntrySize)
+}
+
+// cacheConfig controls staleness and TTL jitter
+type cacheConfig struct {
+	staleTTL time.Duration
+	jitter   float64
+}
+
+// cacheConfigFromEnv reads CACHE_STALE_TTL and CACHE_TTL_JITTER
+func cacheConfigFromEnv() cacheConfig {
+	cfg := cacheConfig{staleTTL: 10 * time.Minute, jitter: 0.1}
+	if d, err := time.ParseDuration(os.Getenv("CACHE_STALE_TTL")); err == nil && d >= 0 {
+		cfg.staleTTL = d
+	}
+	if f, err := strconv.ParseFloat(os.Getenv("CACHE_TTL_JITTER"), 64); err == nil && f >= 0 && f < 1 {
+		cfg.jitter = f
+	}
+	return cfg
+}
+
+// CacheMiddleware returns Gin middleware that caches GET responses in
+// internal/cache. If Redis is unavailable, responses are cached in process
+// only. Which paths are cached, for how long and under which key comes
+// from policy, read afresh for each request so a reload applies at once.
+//
+// Cached responses keep the handler's status and headers and carry an
+// ETag, Last-Modified and a Cache-Control max-age from the policy's TTL;
+// If-None-Match and If-Modified-Since are answered with 304.
+//
+// Concurrent misses for the same key run the handler once and share its
+// response. An expired response is kept for CACHE_STALE_TTL longer and
+// served while one background request through engine refreshes it. TTLs
+// vary by up to CACHE_TTL_JITTER so keys cached together do not all expire
+// at once.
+func CacheMiddleware(engine http.Handler, policy *CachePolicy) gin.HandlerFunc {
+	cfg := cacheConfigFromEnv()
+	var flights singleflight.Group
+	var refreshing sync.Map
+
 	return func(c *gin.Context) {
 		// Only cache GET requests
 		if c.Request.Method != http.MethodGet {
@@ -62,73 +143,281 @@ func CacheMiddleware(defaultTTL time.Duration) gin.HandlerFunc {
 			return
 		}
 
-		path := c.Request.URL.Path
-
-		// Skip non-cacheable endpoints
-		for _, prefix := range skipPrefixes {
-			if strings.HasPrefix(path, prefix) {
-				c.Next()
-				return
-			}
+		// Skip non-cacheable endpoints and requests that ask to bypass
+		rules := policy.load()
+		rule := rules.match(c.Request.URL.Path)
+		if rule.skip || rules.bypassed(rule, c.Request) {
+			c.Next()
+			return
 		}
 
-		// Skip if Redis is not connected
-		if cache.Client == nil {
+		// Skip if neither the in-process cache nor Redis is available
+		if !cache.Enabled() {
 			c.Next()
 			return
 		}
 
-		// Build cache key from full request URI (path + query params)
-		cacheKey := "cache:" + c.Request.URL.RequestURI()
+		// Build cache key from the path and the query params the rule
+		// varies by
+		cacheKey := rule.key(c.Request)
+		prefix, ttl := rule.prefix, rules.ttl(rule, time.Now())
 
-		// Try to serve from cache
-		cached, err := cache.Get(c.Request.Context(), cacheKey)
-		if err != nil {
-			log.Printf("Redis GET error for %s: %v", cacheKey, err)
-		}
-		if cached != "" {
-			c.Header("X-Cache", "HIT")
-			c.Header("Content-Type", "application/json; charset=utf-8")
-			c.String(http.StatusOK, cached)
-			c.Abort()
-			return
+		// Try to serve from cache, unless this is the refresh itself
+		if !IsRefresh(c) {
+			if entry, ok := lookup(c.Request.Context(), cacheKey); ok {
+				if time.Now().Before(entry.FreshUntil) {
+					serveEntry(c, entry, prefix, "HIT", cfg)
+					return
+				}
+				serveEntry(c, entry, prefix, "STALE", cfg)
+				if _, busy := refreshing.LoadOrStore(cacheKey, true); !busy {
+					refresh(engine, c.Request, func() { refreshing.Delete(cacheKey) })
+				}
+				return
+			}
 		}
 
-		// Cache miss — record the response
-		rec := &responseRecorder{
-			ResponseWriter: c.Writer,
-			body:           &bytes.Buffer{},
-		}
-		c.Writer = rec
+		// Cache miss — one request per key runs the handler and records the
+		// response; the others wait for it
+		leader := false
+		value, _, _ := flights.Do(cacheKey, func() (any, error) {
+			leader = true
+			writer := c.Writer
+			rec := &responseRecorder{
+				ResponseWriter: writer,
+				body:           &bytes.Buffer{},
+			}
+			c.Writer = rec
 
-		c.Next()
+			c.Next()
 
-		// Only cache successful JSON responses
-		if c.Writer.Status() != http.StatusOK {
+			c.Writer = writer
+			// Only cache successful responses
+			if writer.Status() != http.StatusOK || rec.body.Len() == 0 {
+				cacheRequestsTotal.WithLabelValues(prefix, "miss").Inc()
+				writer.Header().Set("X-Cache", "MISS")
+				if rec.body.Len() > 0 {
+					writer.Write(rec.body.Bytes())
+				}
+				return (*cacheEntry)(nil), nil
+			}
+
+			entry := newEntry(writer.Status(), writer.Header(), rec.body.String(), ttl, requestTags(c, prefix), cfg)
+			cacheEntrySize.WithLabelValues(prefix).Observe(float64(len(entry.Body)))
+			store(cacheKey, entry, cfg)
+			serveEntry(c, entry, prefix, "MISS", cfg)
+			return entry, nil
+		})
+		if leader {
 			return
 		}
 
-		body := rec.body.String()
-		if body == "" {
+		// Errors are not shared: run the handler for this request too
+		if entry := value.(*cacheEntry); entry != nil {
+			serveEntry(c, entry, prefix, "COALESCED", cfg)
 			return
 		}
+		c.Next()
+	}
+}
 
-		ttl := resolveTTL(path, defaultTTL)
+// lookup reads a cache entry. Entries that cannot be decoded count as
+// misses.
+func lookup(ctx context.Context, key string) (*cacheEntry, bool) {
+	cached, err := cache.Get(ctx, key)
+	if err != nil {
+		log.Printf("Redis GET error for %s: %v", key, err)
+	}
+	var entry cacheEntry
+	if cached == "" || json.Unmarshal([]byte(cached), &entry) != nil || entry.ETag == "" {
+		return nil, false
+	}
+	return &entry, true
+}
 
-		if err := cache.Set(c.Request.Context(), cacheKey, body, ttl); err != nil {
-			log.Printf("Redis SET error for %s: %v", cacheKey, err)
-		}
+// newEntry builds the cache entry for a response, fresh for ttl give or
+// take the jitter. Its ETag is a hash of the body, so every replica gives
+// the same response the same ETag.
+func newEntry(status int, header http.Header, body string, ttl time.Duration, tags []string, cfg cacheConfig) *cacheEntry {
+	stored := header.Clone()
+	for _, name := range unstoredHeaders {
+		stored.Del(name)
+	}
+	sum := sha256.Sum256([]byte(body))
+	// HTTP dates have one-second precision
+	now := time.Now().Truncate(time.Second)
+	ttl += time.Duration(float64(ttl) * cfg.jitter * (2*rand.Float64() - 1))
+	return &cacheEntry{
+		Status:     status,
+		Header:     stored,
+		Body:       body,
+		ETag:       `"` + hex.EncodeToString(sum[:16]) + `"`,
+		Tags:       tags,
+		StoredAt:   now,
+		FreshUntil: now.Add(ttl),
+	}
+}
+
+// store caches entry until it is fresh no longer, plus the stale window.
+// It does not use the request context so a client that hangs up does not
+// lose the response for everyone else.
+func store(key string, entry *cacheEntry, cfg cacheConfig) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
 
-		c.Header("X-Cache", "MISS")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := cache.SetTagged(ctx, key, string(data), time.Until(entry.FreshUntil)+cfg.staleTTL, entry.Tags); err != nil {
+		log.Printf("Redis SET error for %s: %v", key, err)
 	}
 }
 
-// resolveTTL picks the appropriate cache TTL for the given path
-func resolveTTL(path string, defaultTTL time.Duration) time.Duration {
-	for _, o := range ttlOverrides {
-		if strings.HasPrefix(path, o.prefix) {
-			return o.ttl
+// serveEntry writes a cached response, or 304 when the client's copy is
+// still current, and stops the chain
+func serveEntry(c *gin.Context, entry *cacheEntry, prefix, result string, cfg cacheConfig) {
+	cacheRequestsTotal.WithLabelValues(prefix, strings.ToLower(result)).Inc()
+	header := c.Writer.Header()
+	for name, values := range entry.Header {
+		header[name] = slices.Clone(values)
+	}
+	header.Set("X-Cache", result)
+	header.Set("ETag", entry.ETag)
+	header.Set("Last-Modified", entry.StoredAt.UTC().Format(http.TimeFormat))
+	header.Set("Cache-Control", cacheControl(entry, cfg))
+	header.Set("Age", strconv.Itoa(int(time.Since(entry.StoredAt).Seconds())))
+
+	if notModified(c.Request, entry) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Status(entry.Status)
+	c.Writer.WriteString(entry.Body)
+	c.Abort()
+}
+
+// cacheControl lets clients keep a response for its TTL, and use it while
+// stale for as long as the server would
+func cacheControl(entry *cacheEntry, cfg cacheConfig) string {
+	value := fmt.Sprintf("public, max-age=%d", int(entry.FreshUntil.Sub(entry.StoredAt).Seconds()))
+	if cfg.staleTTL > 0 {
+		value += fmt.Sprintf(", stale-while-revalidate=%d", int(cfg.staleTTL.Seconds()))
+	}
+	return value
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when there is
+// no If-None-Match. ETags compare weakly, as RFC 9110 requires for GET.
+func notModified(req *http.Request, entry *cacheEntry) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == entry.ETag {
+				return true
+			}
 		}
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	return err == nil && !entry.StoredAt.After(since)
+}
+
+// refresh replays a copy of req through engine in the background. The
+// middleware recognises the copy, skips the lookup and stores the new
+// response; the response itself is discarded.
+func refresh(engine http.Handler, req *http.Request, done func()) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), refreshKey{}, true), refreshTimeout)
+	replay := req.Clone(ctx)
+	go func() {
+		defer done()
+		defer cancel()
+		engine.ServeHTTP(discardWriter{header: http.Header{}}, replay)
+	}()
+}
+
+// discardWriter is the ResponseWriter of a background refresh
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header         { return w.header }
+func (w discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w discardWriter) WriteHeader(int)             {}
+
+// SymbolTag is the tag of cached responses about a symbol
+func SymbolTag(symbol string) string {
+	return "symbol:" + strings.ToUpper(strings.TrimSpace(symbol))
+}
+
+// EndpointTag is the tag of cached responses from an endpoint family, named
+// by its cache policy prefix (or "default")
+func EndpointTag(prefix string) string {
+	return "endpoint:" + prefix
+}
+
+// requestTags are the tags a response is cached under: its endpoint family
+// and the symbol it is about, if any
+func requestTags(c *gin.Context, prefix string) []string {
+	tags := []string{EndpointTag(prefix)}
+	symbol := c.Param("symbol")
+	if symbol == "" {
+		symbol = c.Query("symbol")
+	}
+	if symbol != "" {
+		tags = append(tags, SymbolTag(symbol))
+	}
+	return tags
+}
+
+// CachedResponse describes a cached response for the admin API
+type CachedResponse struct {
+	Key        string      `json:"key"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	ETag       string      `json:"etag"`
+	Tags       []string    `json:"tags"`
+	StoredAt   time.Time   `json:"stored_at"`
+	FreshUntil time.Time   `json:"fresh_until"`
+	Stale      bool        `json:"stale"`
+	// ExpiresIn is how long the response is kept, stale window included
+	ExpiresIn float64 `json:"expires_in_seconds"`
+	Size      int     `json:"size"`
+	// Body is the JSON body as is, or a string for other content
+	Body any `json:"body"`
+}
+
+// InspectCachedResponse returns the cached response under key, or nil when
+// there is none
+func InspectCachedResponse(ctx context.Context, key string) (*CachedResponse, error) {
+	cached, err := cache.Get(ctx, key)
+	if err != nil || cached == "" {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal([]byte(cached), &entry); err != nil || entry.ETag == "" {
+		return nil, fmt.Errorf("%s does not hold a cached response", key)
+	}
+	ttl, _, err := cache.TTL(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var body any = entry.Body
+	if json.Valid([]byte(entry.Body)) {
+		body = json.RawMessage(entry.Body)
 	}
-	return defaultTTL
+	return &CachedResponse{
+		Key:        key,
+		Status:     entry.Status,
+		Header:     entry.Header,
+		ETag:       entry.ETag,
+		Tags:       entry.Tags,
+		StoredAt:   entry.StoredAt,
+		FreshUntil: entry.FreshUntil,
+		Stale:      time.Now().After(entry.FreshUntil),
+		ExpiresIn:  ttl.Seconds(),
+		Size:       len(entry.Body),
+		Body:       body,
+	}, nil
 }
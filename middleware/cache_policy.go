@@ -0,0 +1,445 @@
+package middleware
+
+import (
+	"bytes"
+	"cmp"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	// The runtime image has no zoneinfo, and market hours need one
+	_ "time/tzdata"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultPolicy is the built-in cache policy, used when CACHE_POLICY_FILE
+// is not set
+//
+//go:embed cache_policy.json
+var defaultPolicy []byte
+
+var policyReloadsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_cache_policy_reloads_total",
+		Help: "Reloads of CACHE_POLICY_FILE by result (success, error)",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(policyReloadsTotal)
+}
+
+// policyFile is the JSON layout of a cache policy
+type policyFile struct {
+	MarketHours struct {
+		Timezone string `json:"timezone"`
+		Open     string `json:"open"`
+		Close    string `json:"close"`
+	} `json:"market_hours"`
+	Default ruleFile     `json:"default"`
+	Bypass  []BypassRule `json:"bypass"`
+	Rules   []ruleFile   `json:"rules"`
+}
+
+// ruleFile is one route in a policy file. Durations are Go duration
+// strings.
+type ruleFile struct {
+	Prefix     string       `json:"prefix"`
+	Skip       bool         `json:"skip"`
+	TTL        string       `json:"ttl"`
+	SessionTTL string       `json:"session_ttl"`
+	ClosedTTL  string       `json:"closed_ttl"`
+	Vary       *[]string    `json:"vary"`
+	Bypass     []BypassRule `json:"bypass"`
+}
+
+// BypassRule sends a request past the cache when it carries the query
+// parameter or header, with Value if set (compared case-insensitively)
+type BypassRule struct {
+	Query  string `json:"query,omitempty"`
+	Header string `json:"header,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+// matches reports whether req meets the condition
+func (b BypassRule) matches(req *http.Request) bool {
+	var values []string
+	if b.Query != "" {
+		values = req.URL.Query()[b.Query]
+	} else {
+		values = req.Header.Values(b.Header)
+	}
+	if b.Value == "" {
+		return len(values) > 0
+	}
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, b.Value) })
+}
+
+// cacheRule is a validated route of the policy
+type cacheRule struct {
+	prefix     string
+	skip       bool
+	sessionTTL time.Duration
+	closedTTL  time.Duration
+	// vary lists the query parameters that make up the cache key, sorted;
+	// nil keys on the whole query string
+	vary   []string
+	bypass []BypassRule
+}
+
+// cachePolicy is a validated policy file
+type cachePolicy struct {
+	location    *time.Location
+	open, close time.Duration // since midnight
+	fallback    cacheRule
+	bypass      []BypassRule
+	rules       []cacheRule
+}
+
+// parsePolicy decodes and validates a policy. Unknown fields are errors, so
+// a misspelt setting is caught rather than ignored.
+func parsePolicy(data []byte) (*cachePolicy, error) {
+	var file policyFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	p := &cachePolicy{}
+	hours := file.MarketHours
+	open, close := cmp.Or(hours.Open, "09:30"), cmp.Or(hours.Close, "16:00")
+	var err error
+	if p.location, err = time.LoadLocation(cmp.Or(hours.Timezone, "America/New_York")); err != nil {
+		return nil, fmt.Errorf("market_hours: %v", err)
+	}
+	if p.open, err = parseClock(open); err != nil {
+		return nil, fmt.Errorf("market_hours.open: %v", err)
+	}
+	if p.close, err = parseClock(close); err != nil {
+		return nil, fmt.Errorf("market_hours.close: %v", err)
+	}
+	if p.open >= p.close {
+		return nil, fmt.Errorf("market_hours: open %s is not before close %s", open, close)
+	}
+
+	if file.Default.Prefix != "" || file.Default.Skip {
+		return nil, fmt.Errorf("default: takes no prefix or skip")
+	}
+	if file.Default.TTL == "" && file.Default.SessionTTL == "" && file.Default.ClosedTTL == "" {
+		file.Default.TTL = "5m"
+	}
+	if p.fallback, err = parseRule(file.Default); err != nil {
+		return nil, fmt.Errorf("default: %v", err)
+	}
+	p.fallback.prefix = defaultPrefix
+	if p.bypass, err = checkBypass(file.Bypass); err != nil {
+		return nil, fmt.Errorf("bypass: %v", err)
+	}
+
+	for i, rf := range file.Rules {
+		if !strings.HasPrefix(rf.Prefix, "/") {
+			return nil, fmt.Errorf("rule %d: prefix %q must start with /", i, rf.Prefix)
+		}
+		// Rules match in order, so one behind a shorter prefix of itself
+		// would never apply
+		for _, earlier := range p.rules {
+			if strings.HasPrefix(rf.Prefix, earlier.prefix) {
+				return nil, fmt.Errorf("rule %d: %s is unreachable after %s; list it first", i, rf.Prefix, earlier.prefix)
+			}
+		}
+		rule, err := parseRule(rf)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %v", i, rf.Prefix, err)
+		}
+		p.rules = append(p.rules, rule)
+	}
+	return p, nil
+}
+
+// parseRule validates one route. A skip rule takes no other settings; any
+// other needs a TTL for both the session and the close, from ttl or their
+// own settings.
+func parseRule(rf ruleFile) (cacheRule, error) {
+	rule := cacheRule{prefix: rf.Prefix, skip: rf.Skip}
+	if rf.Skip {
+		if rf.TTL != "" || rf.SessionTTL != "" || rf.ClosedTTL != "" || rf.Vary != nil || rf.Bypass != nil {
+			return rule, fmt.Errorf("skip takes no other settings")
+		}
+		return rule, nil
+	}
+
+	ttl, err := parseTTL("ttl", rf.TTL)
+	if err != nil {
+		return rule, err
+	}
+	if rule.sessionTTL, err = parseTTL("session_ttl", rf.SessionTTL); err != nil {
+		return rule, err
+	}
+	if rule.closedTTL, err = parseTTL("closed_ttl", rf.ClosedTTL); err != nil {
+		return rule, err
+	}
+	rule.sessionTTL = cmp.Or(rule.sessionTTL, ttl)
+	rule.closedTTL = cmp.Or(rule.closedTTL, ttl)
+	if rule.sessionTTL == 0 || rule.closedTTL == 0 {
+		return rule, fmt.Errorf("needs ttl, or both session_ttl and closed_ttl")
+	}
+
+	if rf.Vary != nil {
+		rule.vary = slices.Clone(*rf.Vary)
+		slices.Sort(rule.vary)
+		rule.vary = slices.Compact(rule.vary)
+		if slices.Contains(rule.vary, "") {
+			return rule, fmt.Errorf("vary has an empty parameter name")
+		}
+	}
+	if rule.bypass, err = checkBypass(rf.Bypass); err != nil {
+		return rule, fmt.Errorf("bypass: %v", err)
+	}
+	return rule, nil
+}
+
+// parseTTL parses a positive duration; empty is zero
+func parseTTL(name, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s: %s is not positive", name, value)
+	}
+	return d, nil
+}
+
+// parseClock parses HH:MM into the time since midnight
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func checkBypass(rules []BypassRule) ([]BypassRule, error) {
+	for i, b := range rules {
+		if (b.Query == "") == (b.Header == "") {
+			return nil, fmt.Errorf("condition %d needs exactly one of query or header", i)
+		}
+	}
+	return rules, nil
+}
+
+// match returns the first rule whose prefix starts path, or the default
+func (p *cachePolicy) match(path string) *cacheRule {
+	for i := range p.rules {
+		if strings.HasPrefix(path, p.rules[i].prefix) {
+			return &p.rules[i]
+		}
+	}
+	return &p.fallback
+}
+
+// bypassed reports whether req meets a global or rule bypass condition
+func (p *cachePolicy) bypassed(rule *cacheRule, req *http.Request) bool {
+	for _, b := range p.bypass {
+		if b.matches(req) {
+			return true
+		}
+	}
+	for _, b := range rule.bypass {
+		if b.matches(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// ttl is how long a response under rule stays fresh when cached at now.
+// Outside market hours it gets the closed TTL, but only until the next
+// open (and never less than the session TTL), so an overnight entry does
+// not outlive the close into the session.
+func (p *cachePolicy) ttl(rule *cacheRule, now time.Time) time.Duration {
+	open, untilOpen := p.session(now)
+	if open {
+		return rule.sessionTTL
+	}
+	return max(min(rule.closedTTL, untilOpen), rule.sessionTTL)
+}
+
+// session reports whether the market is open at now and, if not, how long
+// until it opens. Sessions run from open to close, Monday to Friday;
+// exchange holidays are treated as trading days.
+func (p *cachePolicy) session(now time.Time) (bool, time.Duration) {
+	local := now.In(p.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, p.location)
+	sinceMidnight := local.Sub(midnight)
+	if weekday(local) && sinceMidnight >= p.open && sinceMidnight < p.close {
+		return true, 0
+	}
+
+	day := midnight
+	if sinceMidnight >= p.open {
+		day = day.AddDate(0, 0, 1)
+	}
+	for !weekday(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	// Set the clock time on the date rather than adding it to midnight, so
+	// a DST change overnight does not shift the open
+	opens := time.Date(day.Year(), day.Month(), day.Day(),
+		int(p.open/time.Hour), int(p.open%time.Hour/time.Minute), 0, 0, p.location)
+	return false, opens.Sub(now)
+}
+
+func weekday(t time.Time) bool {
+	return t.Weekday() != time.Saturday && t.Weekday() != time.Sunday
+}
+
+// key is the cache key of req under rule: the path and, if the rule lists
+// vary parameters, only those, in a fixed order; otherwise the whole query
+// string as sent
+func (rule *cacheRule) key(req *http.Request) string {
+	if rule.vary == nil {
+		return CacheKeyPrefix + req.URL.RequestURI()
+	}
+	query := req.URL.Query()
+	kept := url.Values{}
+	for _, name := range rule.vary {
+		if values, ok := query[name]; ok {
+			kept[name] = values
+		}
+	}
+	key := CacheKeyPrefix + req.URL.EscapedPath()
+	if len(kept) > 0 {
+		// Encode sorts by name
+		key += "?" + kept.Encode()
+	}
+	return key
+}
+
+// CachePolicy is the response cache's routing: which paths are cached, for
+// how long and under which key. It is read from a JSON file and can be
+// reloaded while the server runs; see cache_policy.json for the built-in
+// one.
+type CachePolicy struct {
+	path    string
+	current atomic.Pointer[cachePolicy]
+
+	mutex   sync.Mutex
+	modTime time.Time
+}
+
+// LoadCachePolicy reads and validates the policy at path, or the built-in
+// one when path is empty
+func LoadCachePolicy(path string) (*CachePolicy, error) {
+	p := &CachePolicy{path: path}
+	if path == "" {
+		policy, err := parsePolicy(defaultPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("built-in cache policy: %v", err)
+		}
+		p.current.Store(policy)
+		return p, nil
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the policy file. An invalid file leaves the policy in
+// use unchanged.
+func (p *CachePolicy) Reload() error {
+	if p.path == "" {
+		return nil
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.reload()
+}
+
+func (p *CachePolicy) reload() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read cache policy: %v", err)
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read cache policy: %v", err)
+	}
+	policy, err := parsePolicy(data)
+	if err != nil {
+		return fmt.Errorf("invalid cache policy %s: %v", p.path, err)
+	}
+	p.current.Store(policy)
+	p.modTime = info.ModTime()
+	return nil
+}
+
+// Watch reloads the policy file whenever its modification time changes,
+// checking every CACHE_POLICY_RELOAD (default 30s; 0 disables), until the
+// returned stop function is called. The built-in policy is not watched.
+func (p *CachePolicy) Watch() (stop func()) {
+	interval := 30 * time.Second
+	if d, err := time.ParseDuration(os.Getenv("CACHE_POLICY_RELOAD")); err == nil && d >= 0 {
+		interval = d
+	}
+	if p.path == "" || interval == 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				p.reloadIfChanged()
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (p *CachePolicy) reloadIfChanged() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	info, err := os.Stat(p.path)
+	if err != nil || info.ModTime().Equal(p.modTime) {
+		return
+	}
+	if err := p.reload(); err != nil {
+		policyReloadsTotal.WithLabelValues("error").Inc()
+		// Don't report the same broken file every tick
+		p.modTime = info.ModTime()
+		log.Printf("Keeping the previous cache policy: %v", err)
+		return
+	}
+	policyReloadsTotal.WithLabelValues("success").Inc()
+	log.Printf("Reloaded cache policy from %s (%d rules)", p.path, len(p.current.Load().rules))
+}
+
+func (p *CachePolicy) load() *cachePolicy {
+	return p.current.Load()
+}
@@ -12,6 +12,12 @@ import (
 // DatabaseLogger middleware logs all API requests to the database
 func DatabaseLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Background cache refreshes are not API requests
+		if IsRefresh(c) {
+			c.Next()
+			return
+		}
+
 		start := time.Now()
 
 		// Process request
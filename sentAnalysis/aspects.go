@@ -0,0 +1,277 @@
+package sentAnalysis
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/MadebyDaris/dogonomics/BertInference"
+)
+
+// Aspects (event types) a news item can be about
+const (
+	AspectEarnings   = "earnings"
+	AspectGuidance   = "guidance"
+	AspectLegal      = "legal"
+	AspectMergers    = "mergers"
+	AspectManagement = "management"
+	AspectProduct    = "product"
+	AspectMacro      = "macro"
+)
+
+// Aspects in report order
+var Aspects = []string{AspectEarnings, AspectGuidance, AspectLegal, AspectMergers, AspectManagement, AspectProduct, AspectMacro}
+
+// aspectPatterns match sentences about each aspect. Matching is on
+// lowercased text at word boundaries. Words that are common outside their
+// aspect ("court", "product", "approval", "economic") only match next to a
+// word that pins down the meaning.
+var aspectPatterns = map[string]*regexp.Regexp{
+	AspectEarnings: aspectPattern(
+		`earnings`, `eps`, `quarterly (?:results|profit|loss|revenue)`, `(?:first|second|third|fourth)[- ]quarter`,
+		`q[1-4] (?:results|earnings|revenue|sales|profit|loss|eps|report|numbers)`,
+		`net (?:income|profit|loss)`, `operating (?:profit|income|loss)`,
+		`(?:quarterly|annual|total|net|record) (?:revenue|sales)`,
+		`(?:revenue|sales) (?:rose|rises?|fell|falls?|grew|grows?|jumped|jumps?|climbed|climbs?|surged|surges?|declined|declines?|dropped|drops?|slipped|slips?|plunged|plunges?|increased|increases?|decreased|decreases?|totaled|totalled|came in|growth|beat|missed)`,
+		`(?:beat|beats|missed|misses|topped|tops) (?:analysts'? )?(?:estimates|expectations|forecasts|consensus)`,
+		`(?:profit|income|loss) per share`, `cents (?:a|per) share`,
+		`(?:earned|lost|earnings of|profit of|loss of) \$?[\d.]+ (?:a|per) share`, `profit warning`,
+		// margins only as a financial measure
+		`(?:gross|operating|profit|net|ebitda|ebit|pre-tax|pretax) margins?`,
+		`margins? (?:expanded|expands?|widened|widens?|narrowed|narrows?|shrank|shrinks?|contracted|contracts?|improved|improves?|compressed|fell|rose)`),
+	AspectGuidance: aspectPattern(
+		`guidance`, `outlook`, `forecasts?`, `(?:revenue|sales|earnings|profit|financial|growth) projections?`,
+		`full[- ]year`, `fiscal (?:year|\d{4})`,
+		`(?:raised|raises|lifted|lifts|cut|cuts|lowered|lowers|reaffirmed|reaffirms|maintained|maintains|withdrew|withdraws) (?:its |the )?(?:guidance|outlook|forecast|target)`,
+		`expects? (?:revenue|sales|earnings|profit|growth)`),
+	AspectLegal: aspectPattern(
+		`lawsuits?`, `sued`, `sues`, `litigation`, `class[- ]action`, `verdict`, `antitrust`, `subpoena`,
+		`(?:in|to|federal|district|appeals|appellate|supreme|bankruptcy|high|circuit) court`,
+		`court (?:ruled|rules|ruling|rulings|case|cases|filings?|orders?|ordered|documents|battle|fight|challenge|hearing)`,
+		`(?:federal|district|u\.s\.|bankruptcy|presiding|circuit) judge`,
+		`judge (?:ruled|rules|ordered|orders|dismissed|dismisses|rejected|rejects|blocked|blocks|granted|grants|denied|denies|sided)`,
+		`(?:grand|federal) jury`, `jury (?:verdict|trial|found|finds|ruled|awarded|awards|ordered|sided|selection)`,
+		`(?:agreed|agrees|agreeing|moved|moves) to settle`,
+		`settle(?:s|d)? (?:the |a |an )?(?:lawsuit|suit|case|claims?|charges|allegations|litigation|dispute)`,
+		`(?:legal|court|class[- ]action|million|billion) settlement`, `settlement (?:with|over)`,
+		`(?:antitrust|regulatory|federal|criminal|government|fraud|accounting|bribery|corruption|justice department) (?:probe|investigation|inquiry)`,
+		`(?:opened|opens|launched|launches|faces|facing|under) (?:an? )?(?:probe|investigation)`,
+		`(?:antitrust|federal|eu|european|u\.s\.|banking|financial|securities|competition) regulators?`,
+		`regulators? (?:sued|sues|fined|fines|charged|charges|probing|investigating|blocked|blocks|opposed|opposes|challenged|challenges|alleged|allege|alleges|accused|accuse|accuses)`,
+		`(?:sec|securities and exchange commission) (?:probe|investigation|inquiry|charges?|charged|sued|sues|lawsuit|complaint|settlement|settled|settles|subpoena|fined?|enforcement)`,
+		`(?:charged|sued|fined|investigated|probed|subpoenaed) by the (?:sec|securities and exchange commission)`,
+		`doj`, `ftc`, `fined`, `fines? (?:of|totaling|totalling)`, `(?:record|antitrust|regulatory|civil|criminal|million|billion) fines?`,
+		`(?:civil|criminal|monetary|record) penalt(?:y|ies)`, `indict(?:ed|ment)`, `fraud`, `patent infringement`),
+	AspectMergers: aspectPattern(
+		`mergers?`, `acquisitions?`, `acquires?`, `acquired`, `acquiring`, `takeover`, `buyout`,
+		`(?:agreed|agrees|deal|offer|bid) to (?:buy|acquire|purchase)`, `tender offer`, `spin[- ]?off`,
+		`divest(?:s|ed|iture|ment)?`, `m&a`, `stake in`, `joint venture`),
+	AspectManagement: aspectPattern(
+		`ceo`, `cfo`, `coo`, `chief (?:executive|financial|operating) officer`, `chairman`, `chairwoman`,
+		`board of directors`, `resign(?:s|ed|ation)?`, `steps? down`, `stepped down`,
+		`appoint(?:s|ed|ment)?`, `names? .{0,30}(?:ceo|cfo|chief|president)`, `successor`, `succession`,
+		// president, executive and management only with a leadership change
+		`(?:president|executives?|management) (?:resigns?|resigned|retires?|retired|departs?|departed|quits?|was fired|was ousted|shake-?up|overhaul|reshuffle|turnover|changes?)`,
+		`(?:new|interim|incoming|outgoing) (?:president|executives?|management team)`,
+		`(?:hires?|hired|promotes?|promoted|ousts?|ousted|fires?|fired|replaces?|replaced) (?:its |the )?(?:president|executives?|management team)`),
+	AspectProduct: aspectPattern(
+		`launch(?:es|ed|ing)? (?:of )?(?:its |a |an |the )?(?:new|first|next|latest|updated|upgraded)`,
+		`(?:product|commercial|global|official) launch(?:es)?`, `unveil(?:s|ed)?`, `rollout`, `roll out`,
+		`new products?`, `(?:flagship|latest|upcoming|consumer) products?`,
+		`product (?:launch(?:es)?|line(?:up)?|roadmap|portfolio|recalls?|release|pipeline|sales|demand|event)`,
+		`fda`, `(?:fda|ema|marketing|drug) approval`,
+		`approval (?:for|of) (?:its |the |a |an )?(?:new )?(?:drug|device|treatment|therapy|vaccine|medicine)s?`,
+		`approved (?:its |the |a |an )?(?:new )?(?:drug|device|treatment|therapy|vaccine|medicine)s?`,
+		`(?:product|safety|voluntary|vehicle|nationwide|global) recalls?`,
+		`recall(?:s|ed|ing)? (?:of )?(?:about |nearly |roughly |more than |over |its |the |some )?(?:[\d,.]+ )?(?:million |thousand )?(?:vehicles|cars|trucks|units|devices|products)`,
+		`(?:granted|awarded|received|files?|filed) (?:a )?patents?`, `patents? (?:for|on|granted|applications?)`,
+		`(?:medical|new|smart|wearable|mobile) devices?`, `device (?:maker|sales|launch|lineup)`,
+		`(?:new|experimental|cancer|weight[- ]loss|obesity|diabetes|generic|prescription|blockbuster) drugs?`,
+		`drug (?:trials?|candidates?|pipeline|launch|sales|developer)`, `clinical trials?`,
+		`new (?:model|service|platform|feature)s?`),
+	AspectMacro: aspectPattern(
+		`inflation`, `interest rates?`, `rate (?:hike|cut)s?`, `the fed`, `fed's`,
+		`fed (?:chair|chairman|officials?|governors?|minutes|funds?|policy|meeting|decision)`, `federal reserve`, `central bank`,
+		`ecb`, `gdp`, `recession`, `tariffs?`, `trade war`, `unemployment`, `jobs report`, `payrolls`,
+		`treasury yields?`, `bond yields?`, `oil prices?`, `consumer spending`,
+		`(?:the|global|world|u\.s\.|us|chinese|china's|european|euro[- ]?zone|domestic|broader|american|german|japanese) economy`,
+		`economy (?:grew|grows|shrank|shrinks|contracted|contracts|expanded|expands|slowed|slows|slowing|added|lost)`,
+		`economic (?:growth|data|slowdown|downturn|outlook|recovery|uncertainty|indicators?|activity|conditions|policy|crisis|contraction|expansion|report|figures|stimulus)`),
+}
+
+func aspectPattern(alternatives ...string) *regexp.Regexp {
+	return regexp.MustCompile(`\b(?:` + strings.Join(alternatives, "|") + `)\b`)
+}
+
+const (
+	// maxAspectSentences caps how many sentences per aspect and article are
+	// scored, headline first
+	maxAspectSentences = 3
+	// maxEvidenceLength caps the example sentence returned per aspect
+	maxEvidenceLength = 200
+)
+
+// AspectSentiment is the sentiment of the sentences in an article that are
+// about one aspect
+type AspectSentiment struct {
+	Aspect     string  `json:"aspect"`
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+	Score      float64 `json:"score"`
+	// Sentences is how many sentences mention the aspect
+	Sentences int `json:"sentences"`
+	// Evidence is the first sentence that mentions the aspect
+	Evidence string `json:"evidence"`
+}
+
+// AspectSummary aggregates one aspect over the articles that mention it
+type AspectSummary struct {
+	Articles      int     `json:"articles"`
+	Sentiment     float64 `json:"sentiment"` // -1.0 to 1.0
+	Confidence    float64 `json:"confidence"`
+	PositiveRatio float64 `json:"positive_ratio"`
+	NeutralRatio  float64 `json:"neutral_ratio"`
+	NegativeRatio float64 `json:"negative_ratio"`
+}
+
+// ParseAspects parses a comma-separated list of aspects
+func ParseAspects(list string) ([]string, error) {
+	var aspects []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !slices.Contains(Aspects, name) {
+			return nil, fmt.Errorf("unknown aspect %q (valid: %s)", name, strings.Join(Aspects, ", "))
+		}
+		if !slices.Contains(aspects, name) {
+			aspects = append(aspects, name)
+		}
+	}
+	return aspects, nil
+}
+
+// DetectAspects returns, for every aspect the article mentions, the
+// sentences that mention it (headline first)
+func DetectAspects(title, content string) map[string][]string {
+	var sentences []string
+	if title = preprocessText(title); title != "" {
+		sentences = append(sentences, title)
+	}
+	sentences = append(sentences, splitSentences(preprocessText(content))...)
+
+	found := make(map[string][]string)
+	for _, sentence := range sentences {
+		lower := strings.ToLower(sentence)
+		for _, aspect := range Aspects {
+			if aspectPatterns[aspect].MatchString(lower) {
+				found[aspect] = append(found[aspect], sentence)
+			}
+		}
+	}
+	return found
+}
+
+// splitSentences splits text after ., ! or ? followed by whitespace and an
+// upper-case letter or digit
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i := 0; i < len(runes)-2; i++ {
+		if !strings.ContainsRune(".!?", runes[i]) || !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+		if next := runes[i+2]; unicode.IsUpper(next) || unicode.IsDigit(next) {
+			if s := strings.TrimSpace(string(runes[start : i+1])); s != "" {
+				sentences = append(sentences, s)
+			}
+			start = i + 2
+		}
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// aspectDocuments builds one document per aspect the item mentions, in
+// Aspects order, from at most maxAspectSentences of its sentences
+func aspectDocuments(item NewsItem) ([]BertInference.Document, []AspectSentiment) {
+	found := DetectAspects(item.Title, item.Content)
+	var docs []BertInference.Document
+	var partial []AspectSentiment
+	for _, aspect := range Aspects {
+		sentences := found[aspect]
+		if len(sentences) == 0 {
+			continue
+		}
+		docs = append(docs, BertInference.Document{Body: strings.Join(sentences[:min(len(sentences), maxAspectSentences)], " ")})
+		partial = append(partial, AspectSentiment{
+			Aspect:    aspect,
+			Sentences: len(sentences),
+			Evidence:  truncate(sentences[0], maxEvidenceLength),
+		})
+	}
+	return docs, partial
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
+
+// AggregateAspects aggregates per-aspect sentiment over analysed news items
+func AggregateAspects(newsItems []NewsItem) map[string]*AspectSummary {
+	byAspect := make(map[string][]BertInference.BERTSentiment)
+	for _, item := range newsItems {
+		for _, a := range item.Aspects {
+			byAspect[a.Aspect] = append(byAspect[a.Aspect], BertInference.BERTSentiment{
+				Label:      a.Label,
+				Confidence: a.Confidence,
+				Score:      a.Score,
+			})
+		}
+	}
+	if len(byAspect) == 0 {
+		return nil
+	}
+
+	out := make(map[string]*AspectSummary, len(byAspect))
+	for aspect, sentiments := range byAspect {
+		analysis := AggregateSentiments(sentiments)
+		out[aspect] = &AspectSummary{
+			Articles:      len(sentiments),
+			Sentiment:     analysis.OverallSentiment,
+			Confidence:    analysis.Confidence,
+			PositiveRatio: analysis.PositiveRatio,
+			NeutralRatio:  analysis.NeutralRatio,
+			NegativeRatio: analysis.NegativeRatio,
+		}
+	}
+	return out
+}
+
+// FilterByAspect keeps news items that mention any of the aspects (any
+// aspect when empty) with the given sentiment label (any label when empty)
+func FilterByAspect(newsItems []NewsItem, aspects []string, label string) []NewsItem {
+	if len(aspects) == 0 && label == "" {
+		return newsItems
+	}
+	out := make([]NewsItem, 0, len(newsItems))
+	for _, item := range newsItems {
+		for _, a := range item.Aspects {
+			if (len(aspects) == 0 || slices.Contains(aspects, a.Aspect)) && (label == "" || a.Label == label) {
+				out = append(out, item)
+				break
+			}
+		}
+	}
+	return out
+}
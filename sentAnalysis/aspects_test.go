@@ -0,0 +1,73 @@
+package sentAnalysis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetectAspects(t *testing.T) {
+	tests := []struct {
+		sentence string
+		want     []string
+	}{
+		// Keywords in the sense of their aspect
+		{"Gross margins expanded to 46% in the quarter.", []string{AspectEarnings}},
+		{"The company earned $1.20 per share.", []string{AspectEarnings}},
+		{"A federal court ruled against the company.", []string{AspectLegal}},
+		{"The court ruled that the patents were invalid.", []string{AspectLegal}},
+		{"A jury awarded the plaintiffs $500 million.", []string{AspectLegal}},
+		{"The bank agreed to settle the charges.", []string{AspectLegal}},
+		{"Antitrust regulators opened an investigation into the deal.", []string{AspectLegal}},
+		{"The company was fined by the SEC.", []string{AspectLegal}},
+		{"The company unveiled a new product line.", []string{AspectProduct}},
+		{"The drug won FDA approval on Tuesday.", []string{AspectProduct}},
+		{"It will recall 1.2 million vehicles.", []string{AspectProduct}},
+		{"Economic growth slowed as inflation stayed high.", []string{AspectMacro}},
+		{"The U.S. economy grew 2.1% last quarter.", []string{AspectMacro}},
+
+		// The same words in other senses
+		{"The stock traded on thin margins of safety.", nil},
+		{"The brand is on trial in the court of public opinion.", nil},
+		{"The plan was the product of the merger talks.", []string{AspectMergers}},
+		{"The product of two numbers is always even here.", nil},
+		{"Shareholders gave their approval of the merger.", []string{AspectMergers}},
+		{"The board approved the dividend.", nil},
+		{"The company has an economic interest in the venture.", nil},
+		{"Analysts say the jury is still out on the strategy.", nil},
+		{"Shares settled 2% higher after a volatile session.", nil},
+		{"Investors are waiting to see if the rally holds, and that is fine.", nil},
+		{"The CFO recalled that demand was strong.", []string{AspectManagement}},
+		{"The company launched an investigation into the outage.", []string{AspectLegal}},
+		{"The voltage regulator on the devices failed.", nil},
+	}
+	for _, tt := range tests {
+		found := DetectAspects(tt.sentence, "")
+		var got []string
+		for _, aspect := range Aspects {
+			if len(found[aspect]) > 0 {
+				got = append(got, aspect)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("DetectAspects(%q) = %v, want %v", tt.sentence, got, tt.want)
+		}
+	}
+}
+
+func TestDetectAspectsSentences(t *testing.T) {
+	found := DetectAspects("Acme beats earnings estimates",
+		"Net income rose 12%. A federal judge dismissed the lawsuit. Inflation cooled in March.")
+	want := map[string][]string{
+		AspectEarnings: {"Acme beats earnings estimates", "Net income rose 12%."},
+		AspectLegal:    {"A federal judge dismissed the lawsuit."},
+		AspectMacro:    {"Inflation cooled in March."},
+	}
+	if len(found) != len(want) {
+		t.Errorf("found aspects %v, want %v", found, want)
+	}
+	for aspect, sentences := range want {
+		if !slices.Equal(found[aspect], sentences) {
+			t.Errorf("%s sentences = %q, want %q", aspect, found[aspect], sentences)
+		}
+	}
+}
@@ -9,11 +9,14 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/MadebyDaris/dogonomics/BertInference"
-	"github.com/MadebyDaris/dogonomics/internal/workerpool"
+	"github.com/MadebyDaris/dogonomics/internal/NewsClient"
+	"github.com/MadebyDaris/dogonomics/internal/SymbolLinker"
+	"github.com/MadebyDaris/dogonomics/internal/sentimentcache"
 )
 
 var apiKey = os.Getenv("EODHD_API_KEY")
@@ -34,6 +37,8 @@ type StockSentimentAnalysis struct {
 	NeutralRatio     float64 `json:"neutral_ratio"`
 	NegativeRatio    float64 `json:"negative_ratio"`
 	Recommendation   string  `json:"recommendation"`
+	// Aspects aggregates sentiment per aspect over the articles mentioning it
+	Aspects map[string]*AspectSummary `json:"aspects,omitempty"`
 }
 
 type NewsItem struct {
@@ -43,16 +48,23 @@ type NewsItem struct {
 	Link          string                      `json:"link"`
 	Sentiment     Sentiment                   `json:"sentiment"`
 	BERTSentiment BertInference.BERTSentiment `json:"bert_sentiment"` // <-- FinBERT sentiment*
-	Symbols       []string                    `json:"symbols"`
-	Tags          []string                    `json:"tags"`
+	// Per-chunk scores behind BERTSentiment, only returned when requested
+	Chunks  []BertInference.ChunkSentiment `json:"chunks,omitempty"`
+	Symbols SymbolLinker.SymbolList        `json:"symbols"`
+	Tags    []string                       `json:"tags"`
+	// Aspects is the sentiment per aspect the article mentions
+	Aspects []AspectSentiment `json:"aspects,omitempty"`
 }
 
+// newsLimit is how many articles each source contributes per symbol
+const newsLimit = 4
+
 func FetchData(ctx context.Context, symbol string) ([]NewsItem, error) {
 	endpoint := "https://eodhd.com/api/news"
 	params := url.Values{}
 	params.Set("api_token", apiKey)
 	params.Set("s", symbol)
-	params.Set("limit", "4")
+	params.Set("limit", strconv.Itoa(newsLimit))
 	fullURL := fmt.Sprintf("%s?%s", endpoint, params.Encode())
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
@@ -65,98 +77,87 @@ func FetchData(ctx context.Context, symbol string) ([]NewsItem, error) {
 		return nil, respErr
 	}
 	defer resp.Body.Close()
-	body, _err := io.ReadAll(resp.Body)
-	var news []NewsItem
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EODHD news for %s: %v", symbol, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("EODHD news for %s returned %s", symbol, resp.Status)
+	}
 
-	_err = json.Unmarshal(body, &news)
+	var news []NewsItem
+	if err := json.Unmarshal(body, &news); err != nil {
+		return nil, fmt.Errorf("failed to decode EODHD news for %s: %v", symbol, err)
+	}
 
-	if _err != nil {
-		log.Fatal(_err)
+	for i := range news {
+		text := news[i].Title + ". " + news[i].Content
+		news[i].Symbols = SymbolLinker.Default.Link(text, news[i].Symbols...)
 	}
 
 	return news, nil
 }
 
-func AnalyzeNews(title string, content string) (*BertInference.BERTSentiment, error) {
-	fullText := fmt.Sprintf("%s. %s", title, content)
-	text := preprocessText(fullText)
-
-	maxLength := 400
-	if len(fullText) > maxLength {
-		fullText = fullText[:maxLength]
-		if lastPeriod := strings.LastIndex(fullText, "."); lastPeriod > 400 {
-			fullText = fullText[:lastPeriod+1]
-		}
+func AnalyzeNews(title string, content string) (*BertInference.DocumentSentiment, error) {
+	results, err := BertInference.RunBERTInferenceDocuments([]BertInference.Document{NewsDocument(title, content)})
+	if err != nil {
+		return nil, err
 	}
-	return BertInference.RunBERTInference(text, "./sentAnalysis/DoggoFinBERT.onnx")
+	return results[0], nil
 }
 
-func RunBERTInferenceONNX(text, modelPath, vocabPath string) (*BertInference.BERTSentiment, error) {
-	return BertInference.RunBERTInference(text, modelPath)
+// NewsDocument builds the model input for an article. The headline and body
+// are kept apart so the full body can be chunked and the headline weighted
+// on its own.
+func NewsDocument(title string, content string) BertInference.Document {
+	return BertInference.Document{
+		Title: preprocessText(title),
+		Body:  preprocessText(content),
+	}
 }
 
-// FetchStockSentiment analyses news items using a worker pool for concurrent BERT inference.
+// FetchStockSentiment analyses news items with batched BERT inference and aggregates the results.
 func FetchStockSentiment(ctx context.Context, newsItems []NewsItem) *StockSentimentAnalysis {
-	if len(newsItems) == 0 {
-		return &StockSentimentAnalysis{
-			OverallSentiment: 0.0,
-			Confidence:       0.0,
-			NewsCount:        0,
-			Recommendation:   "HOLD",
-			PositiveRatio:    0.0,
-			NegativeRatio:    0.0,
-		}
-	}
+	// Score every article in one batched call
+	AnalyzeItems(ctx, newsItems)
+	return Aggregate(newsItems)
+}
 
-	// Run BERT analysis concurrently via worker pool
-	type sentimentResult struct {
-		index     int
-		sentiment *BertInference.BERTSentiment
-	}
-
-	var (
-		mu      sync.Mutex
-		results []sentimentResult
-	)
-
-	tasks := make([]workerpool.Task, len(newsItems))
-	for i, article := range newsItems {
-		idx := i
-		title := article.Title
-		content := article.Content
-		tasks[i] = func(_ context.Context) error {
-			log.Printf("Processing article %d/%d: %s", idx+1, len(newsItems), title)
-			sentiment, err := AnalyzeNews(title, content)
-			if err != nil {
-				log.Printf("Error analyzing news item: %v", err)
-				return nil // non-fatal; we skip this article
-			}
-			mu.Lock()
-			results = append(results, sentimentResult{index: idx, sentiment: sentiment})
-			mu.Unlock()
-			return nil
+// Aggregate combines already analysed news items into one analysis with
+// per-aspect summaries
+func Aggregate(newsItems []NewsItem) *StockSentimentAnalysis {
+	sentiments := make([]BertInference.BERTSentiment, 0, len(newsItems))
+	for _, item := range newsItems {
+		if item.BERTSentiment.Label == "" || item.BERTSentiment.Confidence < 0.1 {
+			continue
 		}
+		sentiments = append(sentiments, item.BERTSentiment)
 	}
 
-	workerpool.Run(ctx, 3, tasks)
+	analysis := AggregateSentiments(sentiments)
+	analysis.NewsCount = len(newsItems)
+	analysis.Aspects = AggregateAspects(newsItems)
+	return analysis
+}
 
-	// Apply results back to newsItems and compute aggregates
+// AggregateSentiments combines per-article sentiment into a single
+// confidence-weighted score, label ratios and a recommendation.
+func AggregateSentiments(sentiments []BertInference.BERTSentiment) *StockSentimentAnalysis {
 	var totalSentiment float64
 	var totalConfidence float64
 	var positiveCount, negativeCount, neutralCount, validArticles int
 
-	for _, r := range results {
-		if r.sentiment == nil || r.sentiment.Confidence < 0.1 {
+	for _, sentiment := range sentiments {
+		if sentiment.Confidence < 0.1 {
 			continue
 		}
 		validArticles++
-		newsItems[r.index].BERTSentiment = *r.sentiment
 
-		weightedSentiment := r.sentiment.Score * r.sentiment.Confidence
+		weightedSentiment := sentiment.Score * sentiment.Confidence
 		totalSentiment += weightedSentiment
-		totalConfidence += r.sentiment.Confidence
+		totalConfidence += sentiment.Confidence
 
-		switch r.sentiment.Label {
+		switch sentiment.Label {
 		case "positive":
 			positiveCount++
 		case "negative":
@@ -170,7 +171,7 @@ func FetchStockSentiment(ctx context.Context, newsItems []NewsItem) *StockSentim
 		return &StockSentimentAnalysis{
 			OverallSentiment: 0.0,
 			Confidence:       0.0,
-			NewsCount:        len(newsItems),
+			NewsCount:        len(sentiments),
 			Recommendation:   "HOLD",
 		}
 	}
@@ -184,7 +185,7 @@ func FetchStockSentiment(ctx context.Context, newsItems []NewsItem) *StockSentim
 	return &StockSentimentAnalysis{
 		OverallSentiment: avgSentiment,
 		Confidence:       avgConfidence,
-		NewsCount:        len(newsItems),
+		NewsCount:        len(sentiments),
 		PositiveRatio:    positiveRatio,
 		NegativeRatio:    negativeRatio,
 		NeutralRatio:     neutralRatio,
@@ -192,6 +193,33 @@ func FetchStockSentiment(ctx context.Context, newsItems []NewsItem) *StockSentim
 	}
 }
 
+// AggregateByTicker attributes each analysed article to every ticker it
+// mentions and aggregates sentiment per ticker.
+func AggregateByTicker(newsItems []NewsItem) map[string]*StockSentimentAnalysis {
+	byTicker := make(map[string][]NewsItem)
+	for _, item := range newsItems {
+		if item.BERTSentiment.Label == "" {
+			continue
+		}
+		for _, symbol := range item.Symbols {
+			byTicker[symbol] = append(byTicker[symbol], item)
+		}
+	}
+
+	out := make(map[string]*StockSentimentAnalysis, len(byTicker))
+	for symbol, items := range byTicker {
+		sentiments := make([]BertInference.BERTSentiment, len(items))
+		for i, item := range items {
+			sentiments[i] = item.BERTSentiment
+		}
+		analysis := AggregateSentiments(sentiments)
+		analysis.Symbol = symbol
+		analysis.Aspects = AggregateAspects(items)
+		out[symbol] = analysis
+	}
+	return out
+}
+
 func generateRecommendation(sentiment, confidence, positiveRatio, negativeRatio float64) string {
 	// High confidence required for strong recommendations
 	if confidence < 0.6 {
@@ -221,29 +249,111 @@ func generateRecommendation(sentiment, confidence, positiveRatio, negativeRatio
 	return "HOLD"
 }
 
-// FetchAndAnalyzeNews fetches news and runs BERT analysis via worker pool.
-func FetchAndAnalyzeNews(ctx context.Context, symbol string) ([]NewsItem, error) {
+// FetchSymbolNews returns EODHD news for a symbol followed by entries from
+// the RSS/Atom feeds nc maps to it, e.g. the company's IR page. It fails
+// only when EODHD fails and no feed has entries.
+func FetchSymbolNews(ctx context.Context, nc *NewsClient.NewsClient, symbol string) ([]NewsItem, error) {
 	newsItems, err := FetchData(ctx, symbol)
+	var feedItems []NewsItem
+	if nc != nil {
+		for _, article := range nc.GetFeedNewsBySymbol(ctx, symbol, newsLimit) {
+			feedItems = append(feedItems, NewsItemFromArticle(article))
+		}
+	}
+	if err != nil {
+		if len(feedItems) == 0 {
+			return nil, err
+		}
+		log.Printf("EODHD news for %s unavailable, using feeds only: %v", symbol, err)
+	}
+	return append(newsItems, feedItems...), nil
+}
+
+// NewsItemFromArticle converts a multi-source article so it can be scored
+// and stored like EODHD news
+func NewsItemFromArticle(article NewsClient.NewsArticle) NewsItem {
+	content := article.Content
+	if content == "" {
+		content = article.Description
+	}
+	var date string
+	if !article.PublishedAt.IsZero() {
+		date = article.PublishedAt.Format(time.RFC3339)
+	}
+	var tags []string
+	if article.Category != "" {
+		tags = []string{article.Category}
+	}
+	return NewsItem{
+		Title:   article.Title,
+		Content: content,
+		Date:    date,
+		Link:    article.URL,
+		Symbols: article.Symbols,
+		Tags:    tags,
+	}
+}
+
+// FetchAndAnalyzeNews fetches a symbol's news, including the feeds nc maps
+// to it, and runs batched BERT analysis.
+func FetchAndAnalyzeNews(ctx context.Context, nc *NewsClient.NewsClient, symbol string) ([]NewsItem, error) {
+	newsItems, err := FetchSymbolNews(ctx, nc, symbol)
 	if err != nil {
 		return nil, err
 	}
 
-	tasks := make([]workerpool.Task, len(newsItems))
-	for i := range newsItems {
-		idx := i
-		tasks[i] = func(_ context.Context) error {
-			analysis, err := AnalyzeNews(newsItems[idx].Title, newsItems[idx].Content)
-			if err != nil {
-				log.Printf("Error analyzing news item: %v", err)
-				return nil // non-fatal
-			}
-			newsItems[idx].BERTSentiment = *analysis
-			return nil
+	AnalyzeItems(ctx, newsItems)
+	return newsItems, nil
+}
+
+// AnalyzeItems runs FinBERT over already-fetched news items in place,
+// submitting every chunk of every article as one batch. The sentences about
+// each aspect an article mentions are scored in the same batch. If the batch
+// fails, items are left with an empty BERTSentiment.
+func AnalyzeItems(ctx context.Context, newsItems []NewsItem) {
+	if len(newsItems) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	docs := make([]BertInference.Document, len(newsItems))
+	for i, item := range newsItems {
+		docs[i] = NewsDocument(item.Title, item.Content)
+	}
+	aspects := make([][]AspectSentiment, len(newsItems))
+	for i, item := range newsItems {
+		aspectDocs, partial := aspectDocuments(item)
+		docs = append(docs, aspectDocs...)
+		aspects[i] = partial
+	}
+
+	results, err := sentimentcache.RunDocuments(ctx, "", docs)
+	if err != nil {
+		log.Printf("Error analyzing news items: %v", err)
+		return
+	}
+	offset := len(newsItems)
+	for i, sentiment := range results[:len(newsItems)] {
+		newsItems[i].BERTSentiment = sentiment.BERTSentiment
+		newsItems[i].Chunks = sentiment.Chunks
+		for j := range aspects[i] {
+			r := results[offset+j]
+			aspects[i][j].Label = r.Label
+			aspects[i][j].Confidence = r.Confidence
+			aspects[i][j].Score = r.Score
 		}
+		offset += len(aspects[i])
+		newsItems[i].Aspects = aspects[i]
 	}
+}
 
-	workerpool.Run(ctx, 3, tasks)
-	return newsItems, nil
+// AnalyzeArticles runs FinBERT over multi-source news articles in one
+// batch, scoring each on its title and description
+func AnalyzeArticles(ctx context.Context, articles []NewsClient.NewsArticle) ([]*BertInference.DocumentSentiment, error) {
+	docs := make([]BertInference.Document, len(articles))
+	for i, article := range articles {
+		docs[i] = NewsDocument(article.Title, article.Description)
+	}
+	return sentimentcache.RunDocuments(ctx, "", docs)
 }
 
 func preprocessText(text string) string {
@@ -0,0 +1,48 @@
+"""Generate the tokenizer golden file from HuggingFace BertTokenizer.
+
+Run from sentAnalysis/ (like analysis.py) after editing the inputs:
+
+    python tokenizer_golden.py
+
+Writes ../BertInference/testdata/tokenizer_golden.jsonl with one object per
+input line: the text, its WordPiece tokens and the [CLS] ... [SEP] ids. The
+ids refer to the small vocab.txt kept next to it, so the golden file and the
+go test that checks it need no model download. Words missing from it come
+out as [UNK] or ## pieces, which is fine: the algorithm is what is tested.
+
+    go test ./BertInference -run Golden
+
+To compare against the real FinBERT vocab instead, pass it as an argument,
+write the output elsewhere and use go run ./cmd/tokenizercheck.
+"""
+import json
+import os
+import re
+import sys
+
+from transformers import BertTokenizer
+
+HERE = os.path.dirname(os.path.abspath(__file__))
+TESTDATA = os.path.join(HERE, "..", "BertInference", "testdata")
+VOCAB = sys.argv[1] if len(sys.argv) > 1 else os.path.join(TESTDATA, "vocab.txt")
+INPUTS = os.path.join(TESTDATA, "tokenizer_inputs.txt")
+GOLDEN = sys.argv[2] if len(sys.argv) > 2 else os.path.join(TESTDATA, "tokenizer_golden.jsonl")
+
+tokenizer = BertTokenizer(VOCAB, do_lower_case=True)
+
+
+def unescape(line):
+    # Inputs spell control characters as \uXXXX so the file stays printable
+    return re.sub(r"\\u([0-9a-fA-F]{4})", lambda m: chr(int(m.group(1), 16)), line)
+
+
+with open(INPUTS, encoding="utf-8") as f:
+    texts = [unescape(line.rstrip("\n")) for line in f if line.strip()]
+
+with open(GOLDEN, "w", encoding="utf-8") as out:
+    for text in texts:
+        tokens = tokenizer.tokenize(text)
+        ids = tokenizer.encode(text, add_special_tokens=True)
+        out.write(json.dumps({"text": text, "tokens": tokens, "ids": ids}, ensure_ascii=False) + "\n")
+
+print(f"Wrote {len(texts)} cases to {GOLDEN}")